@@ -0,0 +1,77 @@
+// Package auth provides the shared-secret token used to authenticate
+// clients when the server is listening on a TCP port.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataKey is the grpc metadata key which carries the token
+const MetadataKey = "authorization"
+
+// ReadToken returns the token stored in path. The file must not be
+// readable or writable by anyone other than its owner.
+func ReadToken(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("token file %s must have 0600 permissions but has %o", path, fi.Mode().Perm())
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// EnsureToken returns the token stored in path, creating the file with a
+// new random token if it does not exist yet.
+func EnsureToken(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return ReadToken(path)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Credentials implements grpc's credentials.PerRPCCredentials by sending
+// the token with every request
+type Credentials struct {
+	Token string
+	// Secure should be true when the connection uses TLS
+	Secure bool
+}
+
+// GetRequestMetadata returns the token as request metadata
+func (c Credentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{MetadataKey: "Bearer " + c.Token}, nil
+}
+
+// RequireTransportSecurity reports whether the token may only be sent over
+// TLS
+func (c Credentials) RequireTransportSecurity() bool {
+	return c.Secure
+}
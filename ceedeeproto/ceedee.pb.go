@@ -6,10 +6,11 @@ package ceedeeproto
 import (
 	context "context"
 	fmt "fmt"
-	math "math"
-
 	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -24,7 +25,9 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type Directory struct {
-	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// cwd is the client's working directory, if known
+	Cwd                  string   `protobuf:"bytes,2,opt,name=cwd,proto3" json:"cwd,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -62,8 +65,17 @@ func (m *Directory) GetName() string {
 	return ""
 }
 
+func (m *Directory) GetCwd() string {
+	if m != nil {
+		return m.Cwd
+	}
+	return ""
+}
+
 type Dlist struct {
-	Dirs                 string   `protobuf:"bytes,1,opt,name=dirs,proto3" json:"dirs,omitempty"`
+	Dirs string `protobuf:"bytes,1,opt,name=dirs,proto3" json:"dirs,omitempty"`
+	// incomplete is set while the server is still building its index
+	Incomplete           bool     `protobuf:"varint,2,opt,name=incomplete,proto3" json:"incomplete,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -101,6 +113,13 @@ func (m *Dlist) GetDirs() string {
 	return ""
 }
 
+func (m *Dlist) GetIncomplete() bool {
+	if m != nil {
+		return m.Incomplete
+	}
+	return false
+}
+
 type Void struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -132,95 +151,1763 @@ func (m *Void) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Void proto.InternalMessageInfo
 
-func init() {
-	proto.RegisterType((*Directory)(nil), "ceedeeproto.Directory")
-	proto.RegisterType((*Dlist)(nil), "ceedeeproto.Dlist")
-	proto.RegisterType((*Void)(nil), "ceedeeproto.Void")
+type Path struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("ceedee.proto", fileDescriptor_db6621867960c145) }
+func (m *Path) Reset()         { *m = Path{} }
+func (m *Path) String() string { return proto.CompactTextString(m) }
+func (*Path) ProtoMessage()    {}
+func (*Path) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{3}
+}
 
-var fileDescriptor_db6621867960c145 = []byte{
-	// 138 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x49, 0x4e, 0x4d, 0x4d,
-	0x49, 0x4d, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x86, 0xf0, 0xc0, 0x1c, 0x25, 0x79,
-	0x2e, 0x4e, 0x97, 0xcc, 0xa2, 0xd4, 0xe4, 0x92, 0xfc, 0xa2, 0x4a, 0x21, 0x21, 0x2e, 0x96, 0xbc,
-	0xc4, 0xdc, 0x54, 0x09, 0x46, 0x05, 0x46, 0x0d, 0xce, 0x20, 0x30, 0x5b, 0x49, 0x9a, 0x8b, 0xd5,
-	0x25, 0x27, 0xb3, 0xb8, 0x04, 0x24, 0x99, 0x92, 0x59, 0x54, 0x0c, 0x93, 0x04, 0xb1, 0x95, 0xd8,
-	0xb8, 0x58, 0xc2, 0xf2, 0x33, 0x53, 0x8c, 0x6c, 0xb9, 0xd8, 0x9c, 0x53, 0x53, 0x5d, 0x52, 0x53,
-	0x85, 0x8c, 0xb9, 0x98, 0xdd, 0x53, 0x4b, 0x84, 0xc4, 0xf4, 0x90, 0x2c, 0xd1, 0x83, 0xdb, 0x20,
-	0x25, 0x84, 0x2a, 0x0e, 0x32, 0x58, 0x89, 0x21, 0x89, 0x0d, 0xcc, 0x35, 0x06, 0x04, 0x00, 0x00,
-	0xff, 0xff, 0xfe, 0xc8, 0xff, 0xb7, 0xa8, 0x00, 0x00, 0x00,
+func (m *Path) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Path.Unmarshal(m, b)
+}
+func (m *Path) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Path.Marshal(b, m, deterministic)
+}
+func (m *Path) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Path.Merge(m, src)
+}
+func (m *Path) XXX_Size() int {
+	return xxx_messageInfo_Path.Size(m)
+}
+func (m *Path) XXX_DiscardUnknown() {
+	xxx_messageInfo_Path.DiscardUnknown(m)
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+var xxx_messageInfo_Path proto.InternalMessageInfo
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *Path) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
 
-// CeeDeeClient is the client API for CeeDee service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type CeeDeeClient interface {
-	Get(ctx context.Context, in *Directory, opts ...grpc.CallOption) (*Dlist, error)
+type ListRequest struct {
+	// tag, if set, only lists paths with that tag
+	Tag                  string   `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-type ceeDeeClient struct {
-	cc *grpc.ClientConn
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{4}
 }
 
-func NewCeeDeeClient(cc *grpc.ClientConn) CeeDeeClient {
-	return &ceeDeeClient{cc}
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (m *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(m, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
 }
 
-func (c *ceeDeeClient) Get(ctx context.Context, in *Directory, opts ...grpc.CallOption) (*Dlist, error) {
-	out := new(Dlist)
-	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Get", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetTag() string {
+	if m != nil {
+		return m.Tag
 	}
-	return out, nil
+	return ""
 }
 
-// CeeDeeServer is the server API for CeeDee service.
-type CeeDeeServer interface {
-	Get(context.Context, *Directory) (*Dlist, error)
+// Entry describes a single indexed path
+type Entry struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// source is one of "walk", "history", "visit" or "tag"
+	Source string `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// count is the number of times the path appears in the history
+	Count int32 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// last_visit is the unix time of the last visit, if any
+	LastVisit int64 `protobuf:"varint,4,opt,name=last_visit,json=lastVisit,proto3" json:"last_visit,omitempty"`
+	Depth     int32 `protobuf:"varint,5,opt,name=depth,proto3" json:"depth,omitempty"`
+	Visits    int32 `protobuf:"varint,6,opt,name=visits,proto3" json:"visits,omitempty"`
+	// score ranks the path among others with the same basename
+	Score float64  `protobuf:"fixed64,7,opt,name=score,proto3" json:"score,omitempty"`
+	Tags  []string `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`
+	// project is set for a project root
+	Project              bool     `protobuf:"varint,9,opt,name=project,proto3" json:"project,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func RegisterCeeDeeServer(s *grpc.Server, srv CeeDeeServer) {
-	s.RegisterService(&_CeeDee_serviceDesc, srv)
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+func (*Entry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{5}
 }
 
-func _CeeDee_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Directory)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *Entry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Entry.Unmarshal(m, b)
+}
+func (m *Entry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Entry.Marshal(b, m, deterministic)
+}
+func (m *Entry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Entry.Merge(m, src)
+}
+func (m *Entry) XXX_Size() int {
+	return xxx_messageInfo_Entry.Size(m)
+}
+func (m *Entry) XXX_DiscardUnknown() {
+	xxx_messageInfo_Entry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Entry proto.InternalMessageInfo
+
+func (m *Entry) GetPath() string {
+	if m != nil {
+		return m.Path
 	}
-	if interceptor == nil {
-		return srv.(CeeDeeServer).Get(ctx, in)
+	return ""
+}
+
+func (m *Entry) GetSource() string {
+	if m != nil {
+		return m.Source
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/ceedeeproto.CeeDee/Get",
+	return ""
+}
+
+func (m *Entry) GetCount() int32 {
+	if m != nil {
+		return m.Count
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CeeDeeServer).Get(ctx, req.(*Directory))
+	return 0
+}
+
+func (m *Entry) GetLastVisit() int64 {
+	if m != nil {
+		return m.LastVisit
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-var _CeeDee_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "ceedeeproto.CeeDee",
-	HandlerType: (*CeeDeeServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Get",
-			Handler:    _CeeDee_Get_Handler,
+func (m *Entry) GetDepth() int32 {
+	if m != nil {
+		return m.Depth
+	}
+	return 0
+}
+
+func (m *Entry) GetVisits() int32 {
+	if m != nil {
+		return m.Visits
+	}
+	return 0
+}
+
+func (m *Entry) GetScore() float64 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *Entry) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Entry) GetProject() bool {
+	if m != nil {
+		return m.Project
+	}
+	return false
+}
+
+// ForgetRequest names a path, or a pattern as understood by Go's
+// filepath.Match, to remove from the index
+type ForgetRequest struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// block adds path to the blocklist so that it is never indexed again
+	Block                bool     `protobuf:"varint,2,opt,name=block,proto3" json:"block,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ForgetRequest) Reset()         { *m = ForgetRequest{} }
+func (m *ForgetRequest) String() string { return proto.CompactTextString(m) }
+func (*ForgetRequest) ProtoMessage()    {}
+func (*ForgetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{6}
+}
+
+func (m *ForgetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ForgetRequest.Unmarshal(m, b)
+}
+func (m *ForgetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ForgetRequest.Marshal(b, m, deterministic)
+}
+func (m *ForgetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ForgetRequest.Merge(m, src)
+}
+func (m *ForgetRequest) XXX_Size() int {
+	return xxx_messageInfo_ForgetRequest.Size(m)
+}
+func (m *ForgetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ForgetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ForgetRequest proto.InternalMessageInfo
+
+func (m *ForgetRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ForgetRequest) GetBlock() bool {
+	if m != nil {
+		return m.Block
+	}
+	return false
+}
+
+type ForgetReply struct {
+	Removed              int32    `protobuf:"varint,1,opt,name=removed,proto3" json:"removed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ForgetReply) Reset()         { *m = ForgetReply{} }
+func (m *ForgetReply) String() string { return proto.CompactTextString(m) }
+func (*ForgetReply) ProtoMessage()    {}
+func (*ForgetReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{7}
+}
+
+func (m *ForgetReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ForgetReply.Unmarshal(m, b)
+}
+func (m *ForgetReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ForgetReply.Marshal(b, m, deterministic)
+}
+func (m *ForgetReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ForgetReply.Merge(m, src)
+}
+func (m *ForgetReply) XXX_Size() int {
+	return xxx_messageInfo_ForgetReply.Size(m)
+}
+func (m *ForgetReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ForgetReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ForgetReply proto.InternalMessageInfo
+
+func (m *ForgetReply) GetRemoved() int32 {
+	if m != nil {
+		return m.Removed
+	}
+	return 0
+}
+
+// ReloadReply describes each change applied by a reload
+type ReloadReply struct {
+	Changes              []string `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReloadReply) Reset()         { *m = ReloadReply{} }
+func (m *ReloadReply) String() string { return proto.CompactTextString(m) }
+func (*ReloadReply) ProtoMessage()    {}
+func (*ReloadReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{8}
+}
+
+func (m *ReloadReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReloadReply.Unmarshal(m, b)
+}
+func (m *ReloadReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReloadReply.Marshal(b, m, deterministic)
+}
+func (m *ReloadReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReloadReply.Merge(m, src)
+}
+func (m *ReloadReply) XXX_Size() int {
+	return xxx_messageInfo_ReloadReply.Size(m)
+}
+func (m *ReloadReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReloadReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReloadReply proto.InternalMessageInfo
+
+func (m *ReloadReply) GetChanges() []string {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+type ServerStatus struct {
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Pid     int32  `protobuf:"varint,2,opt,name=pid,proto3" json:"pid,omitempty"`
+	// start_time is the unix time the server started at
+	StartTime int64 `protobuf:"varint,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	// indexing is set until the first walk of every root has finished
+	Indexing bool            `protobuf:"varint,4,opt,name=indexing,proto3" json:"indexing,omitempty"`
+	Walks    []*WalkProgress `protobuf:"bytes,5,rep,name=walks,proto3" json:"walks,omitempty"`
+	// names and paths count the distinct basenames and paths in the index
+	Names int32 `protobuf:"varint,6,opt,name=names,proto3" json:"names,omitempty"`
+	Paths int32 `protobuf:"varint,7,opt,name=paths,proto3" json:"paths,omitempty"`
+	// history_entries counts the paths with a history rank or visits
+	HistoryEntries int32 `protobuf:"varint,8,opt,name=history_entries,json=historyEntries,proto3" json:"history_entries,omitempty"`
+	// last_walks describes the most recent completed walk of each root
+	LastWalks    []*WalkSummary `protobuf:"bytes,9,rep,name=last_walks,json=lastWalks,proto3" json:"last_walks,omitempty"`
+	HistoryFiles []*HistoryFile `protobuf:"bytes,10,rep,name=history_files,json=historyFiles,proto3" json:"history_files,omitempty"`
+	// heap_bytes and sys_bytes are the allocated heap and the memory
+	// obtained from the OS
+	HeapBytes uint64 `protobuf:"varint,11,opt,name=heap_bytes,json=heapBytes,proto3" json:"heap_bytes,omitempty"`
+	SysBytes  uint64 `protobuf:"varint,12,opt,name=sys_bytes,json=sysBytes,proto3" json:"sys_bytes,omitempty"`
+	// profile is the profile this status describes and profiles lists
+	// every profile the server hosts
+	Profile              string   `protobuf:"bytes,13,opt,name=profile,proto3" json:"profile,omitempty"`
+	Profiles             []string `protobuf:"bytes,14,rep,name=profiles,proto3" json:"profiles,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
+func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
+func (*ServerStatus) ProtoMessage()    {}
+func (*ServerStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{9}
+}
+
+func (m *ServerStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServerStatus.Unmarshal(m, b)
+}
+func (m *ServerStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServerStatus.Marshal(b, m, deterministic)
+}
+func (m *ServerStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServerStatus.Merge(m, src)
+}
+func (m *ServerStatus) XXX_Size() int {
+	return xxx_messageInfo_ServerStatus.Size(m)
+}
+func (m *ServerStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServerStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServerStatus proto.InternalMessageInfo
+
+func (m *ServerStatus) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *ServerStatus) GetPid() int32 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetIndexing() bool {
+	if m != nil {
+		return m.Indexing
+	}
+	return false
+}
+
+func (m *ServerStatus) GetWalks() []*WalkProgress {
+	if m != nil {
+		return m.Walks
+	}
+	return nil
+}
+
+func (m *ServerStatus) GetNames() int32 {
+	if m != nil {
+		return m.Names
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetPaths() int32 {
+	if m != nil {
+		return m.Paths
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetHistoryEntries() int32 {
+	if m != nil {
+		return m.HistoryEntries
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetLastWalks() []*WalkSummary {
+	if m != nil {
+		return m.LastWalks
+	}
+	return nil
+}
+
+func (m *ServerStatus) GetHistoryFiles() []*HistoryFile {
+	if m != nil {
+		return m.HistoryFiles
+	}
+	return nil
+}
+
+func (m *ServerStatus) GetHeapBytes() uint64 {
+	if m != nil {
+		return m.HeapBytes
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetSysBytes() uint64 {
+	if m != nil {
+		return m.SysBytes
+	}
+	return 0
+}
+
+func (m *ServerStatus) GetProfile() string {
+	if m != nil {
+		return m.Profile
+	}
+	return ""
+}
+
+func (m *ServerStatus) GetProfiles() []string {
+	if m != nil {
+		return m.Profiles
+	}
+	return nil
+}
+
+// WalkProgress describes a directory walk which is running
+type WalkProgress struct {
+	Root string `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`
+	// start_time is the unix time the walk started at
+	StartTime            int64    `protobuf:"varint,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	Dirs                 int32    `protobuf:"varint,3,opt,name=dirs,proto3" json:"dirs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WalkProgress) Reset()         { *m = WalkProgress{} }
+func (m *WalkProgress) String() string { return proto.CompactTextString(m) }
+func (*WalkProgress) ProtoMessage()    {}
+func (*WalkProgress) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{10}
+}
+
+func (m *WalkProgress) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WalkProgress.Unmarshal(m, b)
+}
+func (m *WalkProgress) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WalkProgress.Marshal(b, m, deterministic)
+}
+func (m *WalkProgress) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WalkProgress.Merge(m, src)
+}
+func (m *WalkProgress) XXX_Size() int {
+	return xxx_messageInfo_WalkProgress.Size(m)
+}
+func (m *WalkProgress) XXX_DiscardUnknown() {
+	xxx_messageInfo_WalkProgress.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WalkProgress proto.InternalMessageInfo
+
+func (m *WalkProgress) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+func (m *WalkProgress) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *WalkProgress) GetDirs() int32 {
+	if m != nil {
+		return m.Dirs
+	}
+	return 0
+}
+
+// ReindexReply describes the changes made by a reindex
+type ReindexReply struct {
+	Added   int32 `protobuf:"varint,1,opt,name=added,proto3" json:"added,omitempty"`
+	Removed int32 `protobuf:"varint,2,opt,name=removed,proto3" json:"removed,omitempty"`
+	// truncated is set when a walk was cut short, in which case nothing
+	// was removed
+	Truncated            bool     `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReindexReply) Reset()         { *m = ReindexReply{} }
+func (m *ReindexReply) String() string { return proto.CompactTextString(m) }
+func (*ReindexReply) ProtoMessage()    {}
+func (*ReindexReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{11}
+}
+
+func (m *ReindexReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReindexReply.Unmarshal(m, b)
+}
+func (m *ReindexReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReindexReply.Marshal(b, m, deterministic)
+}
+func (m *ReindexReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReindexReply.Merge(m, src)
+}
+func (m *ReindexReply) XXX_Size() int {
+	return xxx_messageInfo_ReindexReply.Size(m)
+}
+func (m *ReindexReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReindexReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReindexReply proto.InternalMessageInfo
+
+func (m *ReindexReply) GetAdded() int32 {
+	if m != nil {
+		return m.Added
+	}
+	return 0
+}
+
+func (m *ReindexReply) GetRemoved() int32 {
+	if m != nil {
+		return m.Removed
+	}
+	return 0
+}
+
+func (m *ReindexReply) GetTruncated() bool {
+	if m != nil {
+		return m.Truncated
+	}
+	return false
+}
+
+// ImportEntry is a path from another directory jumper's database
+type ImportEntry struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// rank is the path's weight in the database it came from
+	Rank float64 `protobuf:"fixed64,2,opt,name=rank,proto3" json:"rank,omitempty"`
+	// last_visit is the unix time of the last visit, if known
+	LastVisit            int64    `protobuf:"varint,3,opt,name=last_visit,json=lastVisit,proto3" json:"last_visit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportEntry) Reset()         { *m = ImportEntry{} }
+func (m *ImportEntry) String() string { return proto.CompactTextString(m) }
+func (*ImportEntry) ProtoMessage()    {}
+func (*ImportEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{12}
+}
+
+func (m *ImportEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportEntry.Unmarshal(m, b)
+}
+func (m *ImportEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportEntry.Marshal(b, m, deterministic)
+}
+func (m *ImportEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportEntry.Merge(m, src)
+}
+func (m *ImportEntry) XXX_Size() int {
+	return xxx_messageInfo_ImportEntry.Size(m)
+}
+func (m *ImportEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportEntry proto.InternalMessageInfo
+
+func (m *ImportEntry) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ImportEntry) GetRank() float64 {
+	if m != nil {
+		return m.Rank
+	}
+	return 0
+}
+
+func (m *ImportEntry) GetLastVisit() int64 {
+	if m != nil {
+		return m.LastVisit
+	}
+	return 0
+}
+
+type ImportRequest struct {
+	Entries              []*ImportEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ImportRequest) Reset()         { *m = ImportRequest{} }
+func (m *ImportRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportRequest) ProtoMessage()    {}
+func (*ImportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{13}
+}
+
+func (m *ImportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportRequest.Unmarshal(m, b)
+}
+func (m *ImportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportRequest.Marshal(b, m, deterministic)
+}
+func (m *ImportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportRequest.Merge(m, src)
+}
+func (m *ImportRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportRequest.Size(m)
+}
+func (m *ImportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportRequest proto.InternalMessageInfo
+
+func (m *ImportRequest) GetEntries() []*ImportEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// ImportReply counts the paths which were imported and those skipped as
+// they no longer exist
+type ImportReply struct {
+	Imported             int32    `protobuf:"varint,1,opt,name=imported,proto3" json:"imported,omitempty"`
+	Missing              int32    `protobuf:"varint,2,opt,name=missing,proto3" json:"missing,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportReply) Reset()         { *m = ImportReply{} }
+func (m *ImportReply) String() string { return proto.CompactTextString(m) }
+func (*ImportReply) ProtoMessage()    {}
+func (*ImportReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{14}
+}
+
+func (m *ImportReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportReply.Unmarshal(m, b)
+}
+func (m *ImportReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportReply.Marshal(b, m, deterministic)
+}
+func (m *ImportReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportReply.Merge(m, src)
+}
+func (m *ImportReply) XXX_Size() int {
+	return xxx_messageInfo_ImportReply.Size(m)
+}
+func (m *ImportReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportReply proto.InternalMessageInfo
+
+func (m *ImportReply) GetImported() int32 {
+	if m != nil {
+		return m.Imported
+	}
+	return 0
+}
+
+func (m *ImportReply) GetMissing() int32 {
+	if m != nil {
+		return m.Missing
+	}
+	return 0
+}
+
+// Bookmark is a short name for a directory
+type Bookmark struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Path                 string   `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Bookmark) Reset()         { *m = Bookmark{} }
+func (m *Bookmark) String() string { return proto.CompactTextString(m) }
+func (*Bookmark) ProtoMessage()    {}
+func (*Bookmark) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{15}
+}
+
+func (m *Bookmark) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Bookmark.Unmarshal(m, b)
+}
+func (m *Bookmark) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Bookmark.Marshal(b, m, deterministic)
+}
+func (m *Bookmark) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Bookmark.Merge(m, src)
+}
+func (m *Bookmark) XXX_Size() int {
+	return xxx_messageInfo_Bookmark.Size(m)
+}
+func (m *Bookmark) XXX_DiscardUnknown() {
+	xxx_messageInfo_Bookmark.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Bookmark proto.InternalMessageInfo
+
+func (m *Bookmark) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Bookmark) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type BookmarkList struct {
+	Bookmarks            []*Bookmark `protobuf:"bytes,1,rep,name=bookmarks,proto3" json:"bookmarks,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *BookmarkList) Reset()         { *m = BookmarkList{} }
+func (m *BookmarkList) String() string { return proto.CompactTextString(m) }
+func (*BookmarkList) ProtoMessage()    {}
+func (*BookmarkList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{16}
+}
+
+func (m *BookmarkList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BookmarkList.Unmarshal(m, b)
+}
+func (m *BookmarkList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BookmarkList.Marshal(b, m, deterministic)
+}
+func (m *BookmarkList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BookmarkList.Merge(m, src)
+}
+func (m *BookmarkList) XXX_Size() int {
+	return xxx_messageInfo_BookmarkList.Size(m)
+}
+func (m *BookmarkList) XXX_DiscardUnknown() {
+	xxx_messageInfo_BookmarkList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BookmarkList proto.InternalMessageInfo
+
+func (m *BookmarkList) GetBookmarks() []*Bookmark {
+	if m != nil {
+		return m.Bookmarks
+	}
+	return nil
+}
+
+// TagRequest names a path and the tags to add to or remove from it
+type TagRequest struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Tags                 []string `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TagRequest) Reset()         { *m = TagRequest{} }
+func (m *TagRequest) String() string { return proto.CompactTextString(m) }
+func (*TagRequest) ProtoMessage()    {}
+func (*TagRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{17}
+}
+
+func (m *TagRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TagRequest.Unmarshal(m, b)
+}
+func (m *TagRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TagRequest.Marshal(b, m, deterministic)
+}
+func (m *TagRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TagRequest.Merge(m, src)
+}
+func (m *TagRequest) XXX_Size() int {
+	return xxx_messageInfo_TagRequest.Size(m)
+}
+func (m *TagRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TagRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TagRequest proto.InternalMessageInfo
+
+func (m *TagRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *TagRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type UntagReply struct {
+	Removed              int32    `protobuf:"varint,1,opt,name=removed,proto3" json:"removed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UntagReply) Reset()         { *m = UntagReply{} }
+func (m *UntagReply) String() string { return proto.CompactTextString(m) }
+func (*UntagReply) ProtoMessage()    {}
+func (*UntagReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{18}
+}
+
+func (m *UntagReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UntagReply.Unmarshal(m, b)
+}
+func (m *UntagReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UntagReply.Marshal(b, m, deterministic)
+}
+func (m *UntagReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UntagReply.Merge(m, src)
+}
+func (m *UntagReply) XXX_Size() int {
+	return xxx_messageInfo_UntagReply.Size(m)
+}
+func (m *UntagReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_UntagReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UntagReply proto.InternalMessageInfo
+
+func (m *UntagReply) GetRemoved() int32 {
+	if m != nil {
+		return m.Removed
+	}
+	return 0
+}
+
+// WalkSummary describes a completed directory walk
+type WalkSummary struct {
+	Root string `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`
+	// start_time is the unix time the walk started at
+	StartTime  int64 `protobuf:"varint,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	DurationMs int64 `protobuf:"varint,3,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	Dirs       int32 `protobuf:"varint,4,opt,name=dirs,proto3" json:"dirs,omitempty"`
+	// error_skips counts the directories which couldn't be read
+	ErrorSkips int32      `protobuf:"varint,5,opt,name=error_skips,json=errorSkips,proto3" json:"error_skips,omitempty"`
+	SkipHits   []*SkipHit `protobuf:"bytes,6,rep,name=skip_hits,json=skipHits,proto3" json:"skip_hits,omitempty"`
+	// truncated is the reason the walk stopped early, if it did
+	Truncated            string   `protobuf:"bytes,7,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WalkSummary) Reset()         { *m = WalkSummary{} }
+func (m *WalkSummary) String() string { return proto.CompactTextString(m) }
+func (*WalkSummary) ProtoMessage()    {}
+func (*WalkSummary) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{19}
+}
+
+func (m *WalkSummary) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WalkSummary.Unmarshal(m, b)
+}
+func (m *WalkSummary) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WalkSummary.Marshal(b, m, deterministic)
+}
+func (m *WalkSummary) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WalkSummary.Merge(m, src)
+}
+func (m *WalkSummary) XXX_Size() int {
+	return xxx_messageInfo_WalkSummary.Size(m)
+}
+func (m *WalkSummary) XXX_DiscardUnknown() {
+	xxx_messageInfo_WalkSummary.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WalkSummary proto.InternalMessageInfo
+
+func (m *WalkSummary) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+func (m *WalkSummary) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *WalkSummary) GetDurationMs() int64 {
+	if m != nil {
+		return m.DurationMs
+	}
+	return 0
+}
+
+func (m *WalkSummary) GetDirs() int32 {
+	if m != nil {
+		return m.Dirs
+	}
+	return 0
+}
+
+func (m *WalkSummary) GetErrorSkips() int32 {
+	if m != nil {
+		return m.ErrorSkips
+	}
+	return 0
+}
+
+func (m *WalkSummary) GetSkipHits() []*SkipHit {
+	if m != nil {
+		return m.SkipHits
+	}
+	return nil
+}
+
+func (m *WalkSummary) GetTruncated() string {
+	if m != nil {
+		return m.Truncated
+	}
+	return ""
+}
+
+// SkipHit counts the directories skipped by a skip list entry or ignore
+// pattern
+type SkipHit struct {
+	Rule                 string   `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	Count                int32    `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SkipHit) Reset()         { *m = SkipHit{} }
+func (m *SkipHit) String() string { return proto.CompactTextString(m) }
+func (*SkipHit) ProtoMessage()    {}
+func (*SkipHit) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{20}
+}
+
+func (m *SkipHit) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SkipHit.Unmarshal(m, b)
+}
+func (m *SkipHit) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SkipHit.Marshal(b, m, deterministic)
+}
+func (m *SkipHit) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SkipHit.Merge(m, src)
+}
+func (m *SkipHit) XXX_Size() int {
+	return xxx_messageInfo_SkipHit.Size(m)
+}
+func (m *SkipHit) XXX_DiscardUnknown() {
+	xxx_messageInfo_SkipHit.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SkipHit proto.InternalMessageInfo
+
+func (m *SkipHit) GetRule() string {
+	if m != nil {
+		return m.Rule
+	}
+	return ""
+}
+
+func (m *SkipHit) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+// HistoryFile describes a shell history file read by the server
+type HistoryFile struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// offset is how far into the file has been read
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size   int64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Lines  int32 `protobuf:"varint,4,opt,name=lines,proto3" json:"lines,omitempty"`
+	// parse_failures counts the 'cd' commands which couldn't be understood
+	ParseFailures        int32    `protobuf:"varint,5,opt,name=parse_failures,json=parseFailures,proto3" json:"parse_failures,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HistoryFile) Reset()         { *m = HistoryFile{} }
+func (m *HistoryFile) String() string { return proto.CompactTextString(m) }
+func (*HistoryFile) ProtoMessage()    {}
+func (*HistoryFile) Descriptor() ([]byte, []int) {
+	return fileDescriptor_db6621867960c145, []int{21}
+}
+
+func (m *HistoryFile) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HistoryFile.Unmarshal(m, b)
+}
+func (m *HistoryFile) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HistoryFile.Marshal(b, m, deterministic)
+}
+func (m *HistoryFile) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HistoryFile.Merge(m, src)
+}
+func (m *HistoryFile) XXX_Size() int {
+	return xxx_messageInfo_HistoryFile.Size(m)
+}
+func (m *HistoryFile) XXX_DiscardUnknown() {
+	xxx_messageInfo_HistoryFile.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HistoryFile proto.InternalMessageInfo
+
+func (m *HistoryFile) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *HistoryFile) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *HistoryFile) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *HistoryFile) GetLines() int32 {
+	if m != nil {
+		return m.Lines
+	}
+	return 0
+}
+
+func (m *HistoryFile) GetParseFailures() int32 {
+	if m != nil {
+		return m.ParseFailures
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Directory)(nil), "ceedeeproto.Directory")
+	proto.RegisterType((*Dlist)(nil), "ceedeeproto.Dlist")
+	proto.RegisterType((*Void)(nil), "ceedeeproto.Void")
+	proto.RegisterType((*Path)(nil), "ceedeeproto.Path")
+	proto.RegisterType((*ListRequest)(nil), "ceedeeproto.ListRequest")
+	proto.RegisterType((*Entry)(nil), "ceedeeproto.Entry")
+	proto.RegisterType((*ForgetRequest)(nil), "ceedeeproto.ForgetRequest")
+	proto.RegisterType((*ForgetReply)(nil), "ceedeeproto.ForgetReply")
+	proto.RegisterType((*ReloadReply)(nil), "ceedeeproto.ReloadReply")
+	proto.RegisterType((*ServerStatus)(nil), "ceedeeproto.ServerStatus")
+	proto.RegisterType((*WalkProgress)(nil), "ceedeeproto.WalkProgress")
+	proto.RegisterType((*ReindexReply)(nil), "ceedeeproto.ReindexReply")
+	proto.RegisterType((*ImportEntry)(nil), "ceedeeproto.ImportEntry")
+	proto.RegisterType((*ImportRequest)(nil), "ceedeeproto.ImportRequest")
+	proto.RegisterType((*ImportReply)(nil), "ceedeeproto.ImportReply")
+	proto.RegisterType((*Bookmark)(nil), "ceedeeproto.Bookmark")
+	proto.RegisterType((*BookmarkList)(nil), "ceedeeproto.BookmarkList")
+	proto.RegisterType((*TagRequest)(nil), "ceedeeproto.TagRequest")
+	proto.RegisterType((*UntagReply)(nil), "ceedeeproto.UntagReply")
+	proto.RegisterType((*WalkSummary)(nil), "ceedeeproto.WalkSummary")
+	proto.RegisterType((*SkipHit)(nil), "ceedeeproto.SkipHit")
+	proto.RegisterType((*HistoryFile)(nil), "ceedeeproto.HistoryFile")
+}
+
+func init() { proto.RegisterFile("ceedee.proto", fileDescriptor_db6621867960c145) }
+
+var fileDescriptor_db6621867960c145 = []byte{
+	// 1138 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0x5f, 0x8f, 0x1b, 0x35,
+	0x10, 0xcf, 0x26, 0xd9, 0xfc, 0x99, 0xe4, 0x0a, 0x58, 0x47, 0xd9, 0x86, 0x3f, 0x8d, 0x2c, 0x41,
+	0xf3, 0x54, 0xe8, 0x5d, 0xa5, 0x52, 0x55, 0x48, 0xa8, 0xd7, 0x1e, 0x45, 0xa2, 0x52, 0xb5, 0x77,
+	0xd7, 0xbe, 0x20, 0x45, 0xbe, 0xac, 0x2f, 0x31, 0xd9, 0x5d, 0x2f, 0xb6, 0x73, 0x25, 0x7c, 0x04,
+	0x3e, 0x22, 0xef, 0x88, 0x47, 0xbe, 0x02, 0x1a, 0x7b, 0x9d, 0xec, 0xa6, 0x39, 0x04, 0xbc, 0xcd,
+	0x6f, 0x3c, 0xb3, 0x9e, 0x7f, 0xfe, 0xcd, 0xc2, 0x70, 0xc6, 0x79, 0xc2, 0xf9, 0xfd, 0x42, 0x49,
+	0x23, 0xc9, 0xc0, 0x21, 0x0b, 0xe8, 0x03, 0xe8, 0x3f, 0x13, 0x8a, 0xcf, 0x8c, 0x54, 0x6b, 0x42,
+	0xa0, 0x9d, 0xb3, 0x8c, 0x47, 0xc1, 0x38, 0x98, 0xf4, 0x63, 0x2b, 0x93, 0xf7, 0xa1, 0x35, 0x7b,
+	0x9b, 0x44, 0x4d, 0xab, 0x42, 0x91, 0x3e, 0x81, 0xf0, 0x59, 0x2a, 0xb4, 0x41, 0xf3, 0x44, 0x28,
+	0xed, 0xcd, 0x51, 0x26, 0x9f, 0x01, 0x88, 0x7c, 0x26, 0xb3, 0x22, 0xe5, 0x86, 0x5b, 0xaf, 0x5e,
+	0x5c, 0xd1, 0xd0, 0x0e, 0xb4, 0x5f, 0x4b, 0x91, 0xd0, 0x11, 0xb4, 0x5f, 0x31, 0xb3, 0xc0, 0x6f,
+	0x14, 0xcc, 0x2c, 0xfc, 0x37, 0x50, 0xa6, 0x77, 0x61, 0xf0, 0x83, 0xd0, 0x26, 0xe6, 0x3f, 0xaf,
+	0xb8, 0x36, 0x18, 0x81, 0x61, 0xf3, 0xd2, 0x02, 0x45, 0xfa, 0x7b, 0x00, 0xe1, 0xf3, 0xdc, 0xb8,
+	0x88, 0x77, 0xdd, 0xc9, 0x6d, 0xe8, 0x68, 0xb9, 0x52, 0x33, 0x5e, 0x06, 0x5d, 0x22, 0x72, 0x08,
+	0xe1, 0x4c, 0xae, 0x72, 0x13, 0xb5, 0xc6, 0xc1, 0x24, 0x8c, 0x1d, 0x20, 0x9f, 0x02, 0xa4, 0x4c,
+	0x9b, 0xe9, 0xb5, 0xd0, 0xc2, 0x44, 0xed, 0x71, 0x30, 0x69, 0xc5, 0x7d, 0xd4, 0xbc, 0x46, 0x05,
+	0x3a, 0x25, 0xbc, 0x30, 0x8b, 0x28, 0x74, 0x4e, 0x16, 0xe0, 0x15, 0xd6, 0x5e, 0x47, 0x1d, 0xab,
+	0x2e, 0x11, 0x5a, 0xeb, 0x99, 0x54, 0x3c, 0xea, 0x8e, 0x83, 0x49, 0x10, 0x3b, 0x80, 0x41, 0x1a,
+	0x36, 0xd7, 0x51, 0x6f, 0xdc, 0xc2, 0x20, 0x51, 0x26, 0x11, 0x74, 0x0b, 0x25, 0x7f, 0xe2, 0x33,
+	0x13, 0xf5, 0x6d, 0x91, 0x3c, 0xa4, 0x8f, 0xe1, 0xe0, 0x54, 0xaa, 0x39, 0xdf, 0xe4, 0xbf, 0x2f,
+	0xc7, 0x43, 0x08, 0x2f, 0x53, 0x39, 0x5b, 0x96, 0x15, 0x76, 0x80, 0xde, 0x83, 0x81, 0x77, 0x2d,
+	0xd2, 0x35, 0xde, 0xa1, 0x78, 0x26, 0xaf, 0x79, 0x62, 0x7d, 0xc3, 0xd8, 0x43, 0x34, 0x8c, 0x79,
+	0x2a, 0x59, 0xb2, 0x31, 0x9c, 0x2d, 0x58, 0x3e, 0xe7, 0xd8, 0x4b, 0x8c, 0xd1, 0x43, 0xfa, 0x47,
+	0x0b, 0x86, 0x67, 0x5c, 0x5d, 0x73, 0x75, 0x66, 0x98, 0x59, 0xd9, 0xb8, 0xaf, 0xb9, 0xd2, 0x42,
+	0xe6, 0x65, 0x3c, 0x1e, 0x62, 0x9b, 0x0a, 0xe1, 0x06, 0x25, 0x8c, 0x51, 0xc4, 0xd2, 0x6a, 0xc3,
+	0x94, 0x99, 0x1a, 0x91, 0x71, 0x5b, 0xf5, 0x56, 0xdc, 0xb7, 0x9a, 0x73, 0x91, 0x71, 0x32, 0x82,
+	0x9e, 0xc8, 0x13, 0xfe, 0x8b, 0xc8, 0xe7, 0xb6, 0xee, 0xbd, 0x78, 0x83, 0xc9, 0x97, 0x10, 0xbe,
+	0x65, 0xe9, 0x52, 0x47, 0xe1, 0xb8, 0x35, 0x19, 0x1c, 0xdd, 0xb9, 0x5f, 0x99, 0xd9, 0xfb, 0x6f,
+	0x58, 0xba, 0x7c, 0xa5, 0xe4, 0x5c, 0x71, 0xad, 0x63, 0x67, 0x87, 0x05, 0xc1, 0x71, 0xf5, 0x0d,
+	0x71, 0x00, 0xb5, 0x58, 0x2e, 0x6d, 0xfb, 0x11, 0xc6, 0x0e, 0x90, 0x7b, 0xf0, 0xde, 0x42, 0x68,
+	0x9c, 0xf8, 0x29, 0xcf, 0x8d, 0x12, 0x1c, 0x5b, 0x83, 0xe7, 0xb7, 0x4a, 0xf5, 0x73, 0xa7, 0x25,
+	0x8f, 0xca, 0xd9, 0x70, 0xa1, 0xf4, 0x6d, 0x28, 0xd1, 0x3b, 0xa1, 0x9c, 0xad, 0xb2, 0x8c, 0xa9,
+	0xb5, 0x9b, 0x9a, 0x37, 0x36, 0x9a, 0x6f, 0xe0, 0xc0, 0xdf, 0x70, 0x25, 0x52, 0xae, 0x23, 0xd8,
+	0xe3, 0xfb, 0xc2, 0x59, 0x9c, 0x8a, 0x94, 0xc7, 0xc3, 0xc5, 0x16, 0x68, 0x2c, 0xdc, 0x82, 0xb3,
+	0x62, 0x7a, 0xb9, 0x36, 0x5c, 0x47, 0x83, 0x71, 0x30, 0x69, 0xc7, 0x7d, 0xd4, 0x3c, 0x45, 0x05,
+	0xf9, 0x18, 0xfa, 0x7a, 0xad, 0xcb, 0xd3, 0xa1, 0x3d, 0xed, 0xe9, 0xb5, 0x76, 0x87, 0x6e, 0xb0,
+	0xf0, 0xd6, 0xe8, 0xc0, 0x35, 0xa8, 0x84, 0x58, 0xef, 0x52, 0xd4, 0xd1, 0x2d, 0xdb, 0xe6, 0x0d,
+	0xa6, 0x17, 0x30, 0xac, 0x56, 0x15, 0x67, 0x4e, 0x49, 0x69, 0xfc, 0xcc, 0xa1, 0xbc, 0xd3, 0xce,
+	0xe6, 0x6e, 0x3b, 0x3d, 0x1b, 0xb8, 0xd7, 0x65, 0x65, 0xfa, 0x23, 0x0c, 0x63, 0x6e, 0x9b, 0xea,
+	0x06, 0xed, 0x10, 0x42, 0x96, 0x24, 0x9b, 0x79, 0x74, 0xa0, 0x3a, 0xa7, 0xcd, 0xda, 0x9c, 0x92,
+	0x4f, 0xa0, 0x6f, 0xd4, 0x2a, 0x9f, 0x31, 0xc3, 0x13, 0xfb, 0xe1, 0x5e, 0xbc, 0x55, 0xd0, 0x73,
+	0x18, 0x7c, 0x9f, 0x15, 0x52, 0x99, 0x9b, 0xb9, 0x00, 0xf3, 0x60, 0xb9, 0x7b, 0x26, 0x41, 0x6c,
+	0xe5, 0x9d, 0x17, 0xdf, 0xda, 0x79, 0xf1, 0xf4, 0x04, 0x0e, 0xdc, 0x57, 0xfd, 0xfb, 0x3b, 0x82,
+	0xae, 0x1f, 0x93, 0x60, 0x4f, 0x1b, 0x2b, 0x21, 0xc4, 0xde, 0x90, 0x9e, 0xf8, 0xd0, 0x5c, 0xde,
+	0x38, 0xea, 0x16, 0x6e, 0x52, 0xdf, 0x60, 0xcc, 0x3e, 0x13, 0x5a, 0xe3, 0x2b, 0x28, 0xb3, 0x2f,
+	0x21, 0x3d, 0x82, 0xde, 0x53, 0x29, 0x97, 0x19, 0x53, 0xcb, 0xbd, 0xd4, 0xec, 0x13, 0x6e, 0x56,
+	0xb8, 0xf3, 0x04, 0x86, 0xde, 0x07, 0x39, 0x94, 0x1c, 0x43, 0xff, 0xb2, 0xc4, 0x3e, 0xfc, 0x0f,
+	0x6b, 0xe1, 0x7b, 0xeb, 0x78, 0x6b, 0x47, 0x1f, 0x02, 0x9c, 0xb3, 0xf9, 0x3f, 0xf1, 0x8f, 0xa7,
+	0xb4, 0xe6, 0x96, 0xd2, 0xe8, 0x17, 0x00, 0x17, 0xb9, 0x61, 0x73, 0x97, 0xf2, 0xcd, 0xe4, 0xf3,
+	0x67, 0x00, 0x83, 0xca, 0xbb, 0xf9, 0x3f, 0xb3, 0x76, 0x17, 0x06, 0xc9, 0x4a, 0x31, 0x23, 0x64,
+	0x3e, 0xcd, 0x74, 0xd9, 0x43, 0xf0, 0xaa, 0x97, 0x7a, 0x33, 0x8c, 0xed, 0xed, 0x30, 0xa2, 0x13,
+	0x57, 0x4a, 0xaa, 0xa9, 0x5e, 0x8a, 0x42, 0x97, 0x84, 0x0e, 0x56, 0x75, 0x86, 0x1a, 0xf2, 0x00,
+	0xfa, 0x78, 0x34, 0x5d, 0x38, 0x62, 0xc7, 0x5a, 0x1d, 0xd6, 0x6a, 0x85, 0x66, 0x2f, 0x84, 0x89,
+	0x7b, 0xda, 0x09, 0xba, 0x3e, 0xa0, 0x5d, 0x9b, 0xc0, 0x56, 0x41, 0x8f, 0xa1, 0x5b, 0xba, 0xd8,
+	0x24, 0x57, 0xe9, 0xa6, 0x7f, 0x28, 0x6f, 0x17, 0x52, 0xb3, 0xb2, 0x90, 0xe8, 0x6f, 0x01, 0x0c,
+	0x2a, 0xd4, 0x70, 0xd3, 0x8a, 0x93, 0x57, 0x57, 0x9a, 0x9b, 0xb2, 0x34, 0x25, 0x42, 0x5b, 0x2d,
+	0x7e, 0xf5, 0x5c, 0x6b, 0x65, 0xbc, 0x25, 0x15, 0x39, 0xf7, 0xb5, 0x70, 0x80, 0x7c, 0x0e, 0xb7,
+	0x0a, 0xa6, 0x34, 0x9f, 0x5e, 0x31, 0x91, 0xae, 0x14, 0xf7, 0xf5, 0x38, 0xb0, 0xda, 0xd3, 0x52,
+	0x79, 0xf4, 0x57, 0x08, 0x9d, 0x13, 0xce, 0x9f, 0x71, 0x4e, 0x8e, 0xa1, 0xf5, 0x1d, 0x37, 0xe4,
+	0x76, 0xad, 0x22, 0x9b, 0x7f, 0x87, 0x11, 0xa9, 0xeb, 0xf1, 0x07, 0x81, 0x36, 0xc8, 0xd7, 0xd0,
+	0x29, 0x17, 0xc7, 0x07, 0xb5, 0x73, 0xfc, 0x07, 0x18, 0xd5, 0x59, 0xbd, 0xba, 0x66, 0x68, 0x03,
+	0x37, 0x80, 0xdb, 0xc0, 0x75, 0x47, 0xfc, 0x69, 0x18, 0xbd, 0xfb, 0x2d, 0x7b, 0x55, 0xdb, 0x4e,
+	0x7c, 0xfd, 0x75, 0x56, 0x7e, 0x24, 0x76, 0x42, 0xb4, 0x2f, 0x96, 0x36, 0xbe, 0x0a, 0xc8, 0xb7,
+	0xd0, 0x71, 0x6b, 0x93, 0x8c, 0x6a, 0x16, 0xb5, 0x35, 0x3c, 0x8a, 0xf6, 0x9e, 0x15, 0xe9, 0x9a,
+	0x36, 0xc8, 0x23, 0xe8, 0xb8, 0x7d, 0xba, 0x2f, 0xcd, 0xba, 0x63, 0x65, 0xef, 0xd2, 0x06, 0x79,
+	0x0c, 0xdd, 0x92, 0x20, 0xf7, 0xe5, 0x79, 0x67, 0xc7, 0x73, 0xcb, 0xa4, 0xb4, 0x81, 0x51, 0x3b,
+	0x8a, 0xd9, 0x89, 0xba, 0x46, 0x5e, 0xa3, 0x68, 0xef, 0x99, 0xfb, 0xc2, 0x11, 0xb4, 0x5f, 0x22,
+	0xb7, 0xec, 0x27, 0x84, 0xfd, 0x55, 0x7e, 0x08, 0x9d, 0x8b, 0x3c, 0xfb, 0xaf, 0x5e, 0x8f, 0x20,
+	0xc4, 0x9b, 0xfe, 0xc5, 0x14, 0x54, 0xc9, 0x8b, 0x36, 0x70, 0xe8, 0xce, 0xd9, 0x9c, 0x7c, 0x54,
+	0xb3, 0xd9, 0x72, 0xd3, 0xfe, 0xdb, 0x9e, 0x40, 0x68, 0x89, 0xe8, 0x66, 0xb7, 0xfa, 0xc1, 0x96,
+	0xb5, 0x68, 0xe3, 0xb2, 0x63, 0x75, 0xc7, 0x7f, 0x0f, 0x00, 0xfe, 0x65, 0x6c, 0x96, 0x34, 0x0b,
+	0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// CeeDeeClient is the client API for CeeDee service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CeeDeeClient interface {
+	Get(ctx context.Context, in *Directory, opts ...grpc.CallOption) (*Dlist, error)
+	Status(ctx context.Context, in *Void, opts ...grpc.CallOption) (*ServerStatus, error)
+	Visit(ctx context.Context, in *Path, opts ...grpc.CallOption) (*Void, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (CeeDee_ListClient, error)
+	Forget(ctx context.Context, in *ForgetRequest, opts ...grpc.CallOption) (*ForgetReply, error)
+	Reload(ctx context.Context, in *Void, opts ...grpc.CallOption) (*ReloadReply, error)
+	Reindex(ctx context.Context, in *Path, opts ...grpc.CallOption) (*ReindexReply, error)
+	Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error)
+	Mark(ctx context.Context, in *Bookmark, opts ...grpc.CallOption) (*Void, error)
+	Unmark(ctx context.Context, in *Bookmark, opts ...grpc.CallOption) (*Void, error)
+	Marks(ctx context.Context, in *Void, opts ...grpc.CallOption) (*BookmarkList, error)
+	Tag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*Void, error)
+	Untag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*UntagReply, error)
+}
+
+type ceeDeeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCeeDeeClient(cc *grpc.ClientConn) CeeDeeClient {
+	return &ceeDeeClient{cc}
+}
+
+func (c *ceeDeeClient) Get(ctx context.Context, in *Directory, opts ...grpc.CallOption) (*Dlist, error) {
+	out := new(Dlist)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Status(ctx context.Context, in *Void, opts ...grpc.CallOption) (*ServerStatus, error) {
+	out := new(ServerStatus)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Visit(ctx context.Context, in *Path, opts ...grpc.CallOption) (*Void, error) {
+	out := new(Void)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Visit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (CeeDee_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CeeDee_serviceDesc.Streams[0], "/ceedeeproto.CeeDee/List", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ceeDeeListClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CeeDee_ListClient interface {
+	Recv() (*Entry, error)
+	grpc.ClientStream
+}
+
+type ceeDeeListClient struct {
+	grpc.ClientStream
+}
+
+func (x *ceeDeeListClient) Recv() (*Entry, error) {
+	m := new(Entry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *ceeDeeClient) Forget(ctx context.Context, in *ForgetRequest, opts ...grpc.CallOption) (*ForgetReply, error) {
+	out := new(ForgetReply)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Forget", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Reload(ctx context.Context, in *Void, opts ...grpc.CallOption) (*ReloadReply, error) {
+	out := new(ReloadReply)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Reload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Reindex(ctx context.Context, in *Path, opts ...grpc.CallOption) (*ReindexReply, error) {
+	out := new(ReindexReply)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Reindex", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error) {
+	out := new(ImportReply)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Import", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Mark(ctx context.Context, in *Bookmark, opts ...grpc.CallOption) (*Void, error) {
+	out := new(Void)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Mark", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Unmark(ctx context.Context, in *Bookmark, opts ...grpc.CallOption) (*Void, error) {
+	out := new(Void)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Unmark", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Marks(ctx context.Context, in *Void, opts ...grpc.CallOption) (*BookmarkList, error) {
+	out := new(BookmarkList)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Marks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Tag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*Void, error) {
+	out := new(Void)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Tag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceeDeeClient) Untag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*UntagReply, error) {
+	out := new(UntagReply)
+	err := c.cc.Invoke(ctx, "/ceedeeproto.CeeDee/Untag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CeeDeeServer is the server API for CeeDee service.
+type CeeDeeServer interface {
+	Get(context.Context, *Directory) (*Dlist, error)
+	Status(context.Context, *Void) (*ServerStatus, error)
+	Visit(context.Context, *Path) (*Void, error)
+	List(*ListRequest, CeeDee_ListServer) error
+	Forget(context.Context, *ForgetRequest) (*ForgetReply, error)
+	Reload(context.Context, *Void) (*ReloadReply, error)
+	Reindex(context.Context, *Path) (*ReindexReply, error)
+	Import(context.Context, *ImportRequest) (*ImportReply, error)
+	Mark(context.Context, *Bookmark) (*Void, error)
+	Unmark(context.Context, *Bookmark) (*Void, error)
+	Marks(context.Context, *Void) (*BookmarkList, error)
+	Tag(context.Context, *TagRequest) (*Void, error)
+	Untag(context.Context, *TagRequest) (*UntagReply, error)
+}
+
+// UnimplementedCeeDeeServer can be embedded to have forward compatible implementations.
+type UnimplementedCeeDeeServer struct {
+}
+
+func (*UnimplementedCeeDeeServer) Get(ctx context.Context, req *Directory) (*Dlist, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedCeeDeeServer) Status(ctx context.Context, req *Void) (*ServerStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (*UnimplementedCeeDeeServer) Visit(ctx context.Context, req *Path) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Visit not implemented")
+}
+func (*UnimplementedCeeDeeServer) List(req *ListRequest, srv CeeDee_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (*UnimplementedCeeDeeServer) Forget(ctx context.Context, req *ForgetRequest) (*ForgetReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Forget not implemented")
+}
+func (*UnimplementedCeeDeeServer) Reload(ctx context.Context, req *Void) (*ReloadReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reload not implemented")
+}
+func (*UnimplementedCeeDeeServer) Reindex(ctx context.Context, req *Path) (*ReindexReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reindex not implemented")
+}
+func (*UnimplementedCeeDeeServer) Import(ctx context.Context, req *ImportRequest) (*ImportReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (*UnimplementedCeeDeeServer) Mark(ctx context.Context, req *Bookmark) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Mark not implemented")
+}
+func (*UnimplementedCeeDeeServer) Unmark(ctx context.Context, req *Bookmark) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unmark not implemented")
+}
+func (*UnimplementedCeeDeeServer) Marks(ctx context.Context, req *Void) (*BookmarkList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Marks not implemented")
+}
+func (*UnimplementedCeeDeeServer) Tag(ctx context.Context, req *TagRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tag not implemented")
+}
+func (*UnimplementedCeeDeeServer) Untag(ctx context.Context, req *TagRequest) (*UntagReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Untag not implemented")
+}
+
+func RegisterCeeDeeServer(s *grpc.Server, srv CeeDeeServer) {
+	s.RegisterService(&_CeeDee_serviceDesc, srv)
+}
+
+func _CeeDee_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Directory)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Get(ctx, req.(*Directory))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Void)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Status(ctx, req.(*Void))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Visit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Path)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Visit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Visit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Visit(ctx, req.(*Path))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CeeDeeServer).List(m, &ceeDeeListServer{stream})
+}
+
+type CeeDee_ListServer interface {
+	Send(*Entry) error
+	grpc.ServerStream
+}
+
+type ceeDeeListServer struct {
+	grpc.ServerStream
+}
+
+func (x *ceeDeeListServer) Send(m *Entry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CeeDee_Forget_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForgetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Forget(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Forget",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Forget(ctx, req.(*ForgetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Void)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Reload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Reload(ctx, req.(*Void))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Reindex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Path)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Reindex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Reindex",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Reindex(ctx, req.(*Path))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Import_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Import(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Import",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Import(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Mark_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Bookmark)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Mark(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Mark",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Mark(ctx, req.(*Bookmark))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Unmark_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Bookmark)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Unmark(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Unmark",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Unmark(ctx, req.(*Bookmark))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Marks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Void)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Marks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Marks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Marks(ctx, req.(*Void))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Tag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Tag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Tag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Tag(ctx, req.(*TagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeeDee_Untag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeeDeeServer).Untag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ceedeeproto.CeeDee/Untag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeeDeeServer).Untag(ctx, req.(*TagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CeeDee_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "ceedeeproto.CeeDee",
+	HandlerType: (*CeeDeeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _CeeDee_Get_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _CeeDee_Status_Handler,
+		},
+		{
+			MethodName: "Visit",
+			Handler:    _CeeDee_Visit_Handler,
+		},
+		{
+			MethodName: "Forget",
+			Handler:    _CeeDee_Forget_Handler,
+		},
+		{
+			MethodName: "Reload",
+			Handler:    _CeeDee_Reload_Handler,
+		},
+		{
+			MethodName: "Reindex",
+			Handler:    _CeeDee_Reindex_Handler,
+		},
+		{
+			MethodName: "Import",
+			Handler:    _CeeDee_Import_Handler,
+		},
+		{
+			MethodName: "Mark",
+			Handler:    _CeeDee_Mark_Handler,
+		},
+		{
+			MethodName: "Unmark",
+			Handler:    _CeeDee_Unmark_Handler,
+		},
+		{
+			MethodName: "Marks",
+			Handler:    _CeeDee_Marks_Handler,
+		},
+		{
+			MethodName: "Tag",
+			Handler:    _CeeDee_Tag_Handler,
+		},
+		{
+			MethodName: "Untag",
+			Handler:    _CeeDee_Untag_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _CeeDee_List_Handler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "ceedee.proto",
 }
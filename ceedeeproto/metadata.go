@@ -0,0 +1,5 @@
+package ceedeeproto
+
+// ProfileKey is the grpc metadata key which names the profile a call is
+// for. Calls without it use the default profile
+const ProfileKey = "ceedee-profile"
@@ -1,36 +1,517 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
+	log "github.com/sirupsen/logrus"
+	"github.com/walkert/ceedee/auth"
 	pb "github.com/walkert/ceedee/ceedeeproto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-// Client represents a ceedeeproto client
+// DefaultPort is the TCP port used when neither an address nor a socket is
+// given and the default socket does not exist
+const DefaultPort = 2020
+
+// Client represents a ceedeeproto client. A Client holds a single connection
+// which is reused by every call until Close is called
 type Client struct {
-	c pb.CeeDeeClient
+	address   string
+	attempts  int
+	backoff   time.Duration
+	c         pb.CeeDeeClient
+	conn      *grpc.ClientConn
+	cwd       string
+	profile   string
+	socket    string
+	timeout   time.Duration
+	tlsCert   string
+	token     string
+	tokenFile string
+}
+
+// Opt defines a functional option that operates on a Client receiver
+type Opt func(c *Client)
+
+// call runs fn with the client's timeout applied to ctx, unless ctx already
+// has a deadline, and retries it according to the client's retry policy
+// while the server is unavailable
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.once(ctx, fn)
+		if status.Code(err) != codes.Unavailable || attempt >= c.attempts {
+			return err
+		}
+		log.Debugf("Server unavailable, retrying in %s: %v\n", c.backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.backoff):
+		}
+	}
+}
+
+// once runs fn a single time with the client's timeout applied and its
+// profile named in the call's metadata
+func (c *Client) once(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.profile != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, pb.ProfileKey, c.profile)
+	}
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
 }
 
 // Get a directory from the server
-func (c *Client) Get(dir string) ([]string, error) {
-	dlist, err := c.c.Get(context.Background(), &pb.Directory{Name: dir})
+func (c *Client) Get(ctx context.Context, dir string) ([]string, error) {
+	dirs, _, err := c.Lookup(ctx, dir)
+	return dirs, err
+}
+
+// Lookup is like Get but also reports whether the server was still building
+// its index, in which case there may be matches it doesn't know about yet
+func (c *Client) Lookup(ctx context.Context, dir string) ([]string, bool, error) {
+	var dlist *pb.Dlist
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		dlist, err = c.c.Get(ctx, &pb.Directory{Name: dir, Cwd: c.cwd})
+		return err
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "No entry for") {
-			return []string{}, nil
+			incomplete := false
+			for _, detail := range status.Convert(err).Details() {
+				if d, ok := detail.(*pb.Dlist); ok {
+					incomplete = d.Incomplete
+				}
+			}
+			return []string{}, incomplete, nil
 		}
-		return []string{}, err
+		return []string{}, false, err
+	}
+	return strings.Split(dlist.Dirs, ":"), dlist.Incomplete, nil
+}
+
+// Visit records a visit to path, raising its rank
+func (c *Client) Visit(ctx context.Context, path string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.c.Visit(ctx, &pb.Path{Path: path})
+		return err
+	})
+}
+
+// List returns every path in the server's index
+func (c *Client) List(ctx context.Context) ([]*pb.Entry, error) {
+	var entries []*pb.Entry
+	err := c.ListFunc(ctx, func(entry *pb.Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// ListFunc calls fn with each path in the server's index as it arrives,
+// so that large indexes needn't be held in memory. An error from fn stops
+// the listing and is returned. Unlike the other calls, ListFunc is not
+// retried once fn has been called
+func (c *Client) ListFunc(ctx context.Context, fn func(*pb.Entry) error) error {
+	return c.listFunc(ctx, &pb.ListRequest{}, fn)
+}
+
+// ListTagged calls fn with each path in the server's index which carries
+// tag, in the same way as ListFunc
+func (c *Client) ListTagged(ctx context.Context, tag string, fn func(*pb.Entry) error) error {
+	return c.listFunc(ctx, &pb.ListRequest{Tag: tag}, fn)
+}
+
+// listFunc streams the entries selected by req to fn
+func (c *Client) listFunc(ctx context.Context, req *pb.ListRequest, fn func(*pb.Entry) error) error {
+	received := false
+	return c.call(ctx, func(ctx context.Context) error {
+		stream, err := c.c.List(ctx, req)
+		if err != nil {
+			return err
+		}
+		for {
+			entry, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil && received {
+				return fmt.Errorf("the listing was interrupted: %v", err)
+			}
+			if err != nil {
+				return err
+			}
+			received = true
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Forget removes path from the server's index and returns the number of
+// entries removed. path may be a pattern, as understood by filepath.Match,
+// in which case every matching entry is removed
+func (c *Client) Forget(ctx context.Context, path string) (int, error) {
+	return c.forget(ctx, &pb.ForgetRequest{Path: path})
+}
+
+// Block removes path, or every path matching a pattern, and everything
+// below it from the server's index and adds it to the server's blocklist
+// so that it is never indexed again. It returns the number of entries
+// removed
+func (c *Client) Block(ctx context.Context, pattern string) (int, error) {
+	return c.forget(ctx, &pb.ForgetRequest{Path: pattern, Block: true})
+}
+
+// forget sends req to the server and returns the number of entries removed
+func (c *Client) forget(ctx context.Context, req *pb.ForgetRequest) (int, error) {
+	var reply *pb.ForgetReply
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.c.Forget(ctx, req)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	return strings.Split(dlist.Dirs, ":"), nil
+	return int(reply.Removed), nil
 }
 
-// New returns a configured Client object
-func New(port int) (*Client, error) {
-	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", port), grpc.WithInsecure())
+// Status returns the version, pid and start time of the server
+func (c *Client) Status(ctx context.Context) (*pb.ServerStatus, error) {
+	var st *pb.ServerStatus
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		st, err = c.c.Status(ctx, &pb.Void{})
+		return err
+	})
+	return st, err
+}
+
+// Reload asks the server to re-read its configuration and returns a
+// description of each change it applied
+func (c *Client) Reload(ctx context.Context) ([]string, error) {
+	var reply *pb.ReloadReply
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.c.Reload(ctx, &pb.Void{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Changes, nil
+}
+
+// Reindex asks the server to walk path again straight away, or every root
+// if path is empty, and returns how many directories were added and
+// removed. Walks can take a while, so ctx should usually carry a longer
+// deadline than the client's timeout
+func (c *Client) Reindex(ctx context.Context, path string) (*pb.ReindexReply, error) {
+	var reply *pb.ReindexReply
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.c.Reindex(ctx, &pb.Path{Path: path})
+		return err
+	})
+	return reply, err
+}
+
+// Import sends paths read from another directory jumper's database to the
+// server to be merged into its history ranks. The reply counts the paths
+// imported and those skipped as they no longer exist
+func (c *Client) Import(ctx context.Context, entries []*pb.ImportEntry) (*pb.ImportReply, error) {
+	var reply *pb.ImportReply
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.c.Import(ctx, &pb.ImportRequest{Entries: entries})
+		return err
+	})
+	return reply, err
+}
+
+// Mark bookmarks path as name on the server so that a query for name
+// returns path first
+func (c *Client) Mark(ctx context.Context, name, path string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.c.Mark(ctx, &pb.Bookmark{Name: name, Path: path})
+		return err
+	})
+}
+
+// Unmark removes the bookmark name from the server
+func (c *Client) Unmark(ctx context.Context, name string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.c.Unmark(ctx, &pb.Bookmark{Name: name})
+		return err
+	})
+}
+
+// Marks returns the server's bookmarks, sorted by name
+func (c *Client) Marks(ctx context.Context) ([]*pb.Bookmark, error) {
+	var list *pb.BookmarkList
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		list, err = c.c.Marks(ctx, &pb.Void{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Bookmarks, nil
+}
+
+// Tag adds tags to path on the server
+func (c *Client) Tag(ctx context.Context, path string, tags ...string) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.c.Tag(ctx, &pb.TagRequest{Path: path, Tags: tags})
+		return err
+	})
+}
+
+// Untag removes tags from path on the server, or every tag it has if none
+// are given, and returns the number of tags removed
+func (c *Client) Untag(ctx context.Context, path string, tags ...string) (int, error) {
+	var reply *pb.UntagReply
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.c.Untag(ctx, &pb.TagRequest{Path: path, Tags: tags})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(reply.Removed), nil
+}
+
+// Close releases the client's connection to the server
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// RuntimeDir returns the per-user directory for ceedee's socket and other
+// runtime files. This is $XDG_RUNTIME_DIR when it is set, otherwise a
+// private directory under the system temp dir
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ceedee-%d", os.Getuid()))
+}
+
+// CheckRuntimeDir makes sure that the directory RuntimeDir falls back to
+// without $XDG_RUNTIME_DIR belongs to the current user and that nobody else
+// can use it, creating it if it doesn't exist. As it lives in the shared
+// temp dir, another user could otherwise create it first and take over the
+// socket and pid file
+func CheckRuntimeDir() error {
+	if os.Getenv("XDG_RUNTIME_DIR") != "" {
+		return nil
+	}
+	return checkPrivateDir(RuntimeDir())
+}
+
+// checkPrivateDir creates dir with mode 0700 if it doesn't exist and
+// otherwise checks that it is a directory owned by the current user with
+// mode 0700
+func checkPrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err == nil {
+		return os.Chmod(dir, 0700)
+	} else if !os.IsExist(err) {
+		return err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() || fi.Mode().Perm() != 0700 {
+		return fmt.Errorf("refusing to use %s as it isn't a directory owned by uid %d with mode 0700", dir, os.Getuid())
+	}
+	return nil
+}
+
+// DefaultSocket returns the path of the per-user unix domain socket
+func DefaultSocket() string {
+	return filepath.Join(RuntimeDir(), "ceedee.sock")
+}
+
+// WithAddress connects to the server over TCP at addr, e.g. "localhost:2020"
+func WithAddress(addr string) Opt {
+	return func(c *Client) {
+		c.address = addr
+	}
+}
+
+// WithCwd sends dir with every query as the client's working directory,
+// so that the server can search near it for a name it doesn't know
+func WithCwd(dir string) Opt {
+	return func(c *Client) {
+		c.cwd = dir
+	}
+}
+
+// WithProfile makes every call against the server's profile called name
+// rather than its default profile
+func WithProfile(name string) Opt {
+	return func(c *Client) {
+		c.profile = name
+	}
+}
+
+// WithSocket connects to the server over the unix domain socket at path
+// rather than a TCP port
+func WithSocket(path string) Opt {
+	return func(c *Client) {
+		c.socket = path
+	}
+}
+
+// WithTimeout sets a deadline for every call whose context does not
+// already have one
+func WithTimeout(timeout time.Duration) Opt {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithToken sends token with every request made over TCP
+func WithToken(token string) Opt {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithTokenFile sends the shared-secret token stored in path with every
+// request made over TCP
+func WithTokenFile(path string) Opt {
+	return func(c *Client) {
+		c.tokenFile = path
+	}
+}
+
+// WithTLS connects to the server over TLS and only trusts the exact
+// certificate stored in certFile
+func WithTLS(certFile string) Opt {
+	return func(c *Client) {
+		c.tlsCert = certFile
+	}
+}
+
+// WithRetry makes each call up to attempts times, waiting backoff between
+// them, while the server is unavailable
+func WithRetry(attempts int, backoff time.Duration) Opt {
+	return func(c *Client) {
+		c.attempts = attempts
+		c.backoff = backoff
+	}
+}
+
+// pinnedTLS returns TLS credentials which only accept the certificate
+// stored in certFile
+func pinnedTLS(certFile string) (credentials.TransportCredentials, error) {
+	b, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	pinned := block.Bytes
+	return credentials.NewTLS(&tls.Config{
+		// Chain and hostname verification are replaced by the pin check below
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], pinned) {
+				return fmt.Errorf("server certificate does not match %s", certFile)
+			}
+			return nil
+		},
+	}), nil
+}
+
+// New returns a configured Client object. Without WithAddress or WithSocket
+// the client uses the default socket if it exists and localhost:DefaultPort
+// otherwise
+func New(opts ...Opt) (*Client, error) {
+	client := &Client{attempts: 1}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.address == "" && client.socket == "" {
+		if _, err := os.Stat(DefaultSocket()); err == nil {
+			client.socket = DefaultSocket()
+		} else {
+			client.address = fmt.Sprintf("localhost:%d", DefaultPort)
+		}
+	}
+	if client.socket != "" && filepath.Dir(client.socket) == RuntimeDir() {
+		if err := CheckRuntimeDir(); err != nil {
+			return &Client{}, err
+		}
+	}
+	target := client.address
+	var dialOpts []grpc.DialOption
+	if client.socket != "" {
+		target = client.socket
+		dialOpts = append(dialOpts, grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}))
+	} else {
+		if client.tlsCert != "" {
+			creds, err := pinnedTLS(client.tlsCert)
+			if err != nil {
+				return &Client{}, fmt.Errorf("unable to load certificate: %v", err)
+			}
+			dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+		} else {
+			dialOpts = append(dialOpts, grpc.WithInsecure())
+		}
+		if client.tokenFile != "" && client.token == "" {
+			token, err := auth.ReadToken(client.tokenFile)
+			if err != nil {
+				return &Client{}, fmt.Errorf("unable to read token: %v", err)
+			}
+			client.token = token
+		}
+		if client.token != "" {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(auth.Credentials{Token: client.token, Secure: client.tlsCert != ""}))
+		}
+	}
+	conn, err := grpc.Dial(target, dialOpts...)
 	if err != nil {
 		return &Client{}, fmt.Errorf("could not connect to server: %v", err)
 	}
-	return &Client{c: pb.NewCeeDeeClient(conn)}, nil
+	client.conn = conn
+	client.c = pb.NewCeeDeeClient(conn)
+	return client, nil
 }
@@ -1,17 +1,23 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	pb "github.com/walkert/ceedee/ceedeeproto"
 	"github.com/walkert/ceedee/server"
 )
 
 func TestHappyPath(t *testing.T) {
 	s, err := server.New(
 		server.WithRoot("../testdata"),
-		server.WithPort(9910),
+		server.WithPort(0),
 		server.WithSkipList([]string{"ignore"}),
 		server.WithHome("/this/home"),
 		server.WithHistFile("../testdata/histfile"),
@@ -24,12 +30,12 @@ func TestHappyPath(t *testing.T) {
 	go func() {
 		s.Start()
 	}()
-	// Give the hist monitor time to kick in
-	time.Sleep(time.Second * 2)
-	c, err := New(9910)
+	<-s.Ready()
+	c, err := New(WithAddress(s.Addr().String()), WithTimeout(time.Second))
 	if err != nil {
 		t.Fatalf("Unexpected error creating client: %v\n", err)
 	}
+	defer c.Close()
 	tests := []struct {
 		name, search, want string
 		wantCount          int
@@ -55,7 +61,7 @@ func TestHappyPath(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vals, err := c.Get(tt.search)
+			vals, err := c.Get(context.Background(), tt.search)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("Unexpected error getting %s: %v\n", tt.search, err)
@@ -75,3 +81,115 @@ func TestHappyPath(t *testing.T) {
 		})
 	}
 }
+
+func TestVisitListForget(t *testing.T) {
+	s, err := server.New(
+		server.WithRoot("../testdata"),
+		server.WithPort(0),
+		server.WithSkipList([]string{"ignore"}),
+		server.WithHistFile("../testdata/histfile"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := New(WithAddress(s.Addr().String()), WithRetry(3, 100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.Visit(ctx, "/somewhere/else/last"); err != nil {
+		t.Fatalf("Unexpected error visiting: %v\n", err)
+	}
+	vals, err := c.Get(ctx, "last")
+	if err != nil || len(vals) != 2 || vals[0] != "e;/somewhere/else/last" {
+		t.Fatalf("Expected the visited path first but got: %v %v", vals, err)
+	}
+	entries, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error listing: %v\n", err)
+	}
+	sources := make(map[string]string)
+	scores := make(map[string]float64)
+	for _, e := range entries {
+		sources[e.Path] = e.Source
+		scores[e.Path] = e.Score
+	}
+	if sources["/somewhere/else/last"] != "visit" || sources["../testdata/top/next/last"] != "walk" {
+		t.Fatalf("Unexpected sources: %v", sources)
+	}
+	if scores["/somewhere/else/last"] <= scores["../testdata/top/next/last"] {
+		t.Fatalf("Expected the visited path to score highest but got: %v", scores)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err = c.ListFunc(ctx, func(e *pb.Entry) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Fatalf("Expected ListFunc to stop at the first error but got %v after %d calls", err, calls)
+	}
+	removed, err := c.Forget(ctx, "/somewhere/else/last")
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 entry removed but got: %d %v", removed, err)
+	}
+	vals, err = c.Get(ctx, "last")
+	if err != nil || len(vals) != 1 || vals[0] != "e;../testdata/top/next/last" {
+		t.Fatalf("Unexpected values after forget: %v %v", vals, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Unexpected error closing: %v\n", err)
+	}
+	if _, err := c.Get(ctx, "last"); err == nil {
+		t.Fatalf("Expected an error using a closed client")
+	}
+}
+
+func TestCheckPrivateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "missing", valid: true},
+		{name: "missing", valid: true},
+		{name: "shared", valid: false},
+		{name: "link", valid: false},
+		{name: "file", valid: false},
+	}
+	for _, tt := range tests {
+		err := checkPrivateDir(filepath.Join(dir, tt.name))
+		if tt.valid && err != nil {
+			t.Errorf("Unexpected error checking %s: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected an error checking %s", tt.name)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(dir, "missing"))
+	if err != nil || fi.Mode().Perm() != 0700 {
+		t.Errorf("Expected the missing directory to be created with mode 0700 but got %v, %v", fi, err)
+	}
+}
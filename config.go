@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	flag "github.com/spf13/pflag"
+	"github.com/walkert/ceedee/server"
+)
+
+const envPrefix = "CEEDEE_"
+
+var (
+	// notSettings are flags which select what ceedee does rather than how
+	// it is configured, so they can't be set from the config file or the
+	// environment
+	notSettings = map[string]bool{
+		"block":   true,
+		"config":  true,
+		"daemon":  true,
+		"format":  true,
+		"from":    true,
+		"list":    true,
+		"server":  true,
+		"tag":     true,
+		"version": true,
+	}
+	// profileSettings are the settings which can be given for a profile
+	// under [profiles.<name>] in the config file. The rest describe the
+	// daemon or the client rather than an index
+	profileSettings = map[string]bool{
+		"blocklist-file":   true,
+		"dir-interval":     true,
+		"follow-symlinks":  true,
+		"hist-file":        true,
+		"ignore":           true,
+		"max-depth":        true,
+		"max-dirs":         true,
+		"miss-budget":      true,
+		"monitor-interval": true,
+		"path-style":       true,
+		"project-markers":  true,
+		"root":             true,
+		"skip-dirs":        true,
+		"snapshot-file":    true,
+		"walk-timeout":     true,
+		"weight-depth":     true,
+		"weight-history":   true,
+		"weight-project":   true,
+		"weight-proximity": true,
+		"weight-repo":      true,
+		"weight-visit":     true,
+		"xdev":             true,
+	}
+)
+
+// envName returns the environment variable which sets the flag name
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// cmdlineSources returns a map recording that each flag which was given on
+// the command line came from there
+func cmdlineSources(fs *flag.FlagSet) map[string]string {
+	sources := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = "flag"
+	})
+	return sources
+}
+
+// applyConfig layers the config file at path and any CEEDEE_* environment
+// variables underneath the flags given on the command line, so that each
+// setting comes from the first of: flag, environment, config file, default.
+// sources must record the flags given on the command line and is updated
+// with where every other setting that isn't a default came from. The
+// settings of each profile in the config file are returned by name. A
+// missing config file is only an error when required is true
+func applyConfig(fs *flag.FlagSet, path, home string, required bool, sources map[string]string) (map[string]map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		if required || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to read config file %s: %v", path, err)
+		}
+	}
+	profiles, err := configProfiles(values["profiles"], path)
+	if err != nil {
+		return nil, err
+	}
+	delete(values, "profiles")
+	for name, value := range values {
+		f := fs.Lookup(name)
+		if f == nil || notSettings[name] {
+			return nil, fmt.Errorf("unknown setting '%s' in %s", name, path)
+		}
+		if sources[name] == "flag" {
+			continue
+		}
+		s, err := configValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for '%s' in %s: %v", name, path, err)
+		}
+		if err := fs.Set(name, expandHome(s, f, home)); err != nil {
+			return nil, fmt.Errorf("invalid value for '%s' in %s: %v", name, path, err)
+		}
+		sources[name] = "file"
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || notSettings[f.Name] || sources[f.Name] == "flag" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, expandHome(value, f, home)); setErr != nil {
+			err = fmt.Errorf("invalid value for %s: %v", envName(f.Name), setErr)
+			return
+		}
+		sources[f.Name] = "env"
+	})
+	return profiles, err
+}
+
+// configProfiles checks the [profiles.<name>] tables decoded from the
+// config file at path and returns the settings of each profile by name
+func configProfiles(value interface{}, path string) (map[string]map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	tables, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'profiles' in %s must be a table of profiles", path)
+	}
+	profiles := make(map[string]map[string]interface{})
+	for name, table := range tables {
+		settings, ok := table.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' in %s must be a table", name, path)
+		}
+		if name == server.DefaultProfile {
+			return nil, fmt.Errorf("the profile name '%s' in %s is reserved", name, path)
+		}
+		for setting := range settings {
+			if !profileSettings[setting] {
+				return nil, fmt.Errorf("'%s' can't be set for profile %s in %s", setting, name, path)
+			}
+		}
+		profiles[name] = settings
+	}
+	return profiles, nil
+}
+
+// profileOptions returns the options of the profile called name: the
+// effective settings in fs with the profile's own settings layered on top.
+// Unless the profile sets them, its snapshot and blocklist files are named
+// after it so that profiles never share ranking state
+func profileOptions(fs *flag.FlagSet, o *options, name string) (*options, error) {
+	settings, ok := o.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile '%s'", name)
+	}
+	po := &options{home: o.home, profiles: o.profiles, sources: o.sources}
+	pfs := flag.NewFlagSet(name, flag.ContinueOnError)
+	defineFlags(pfs, po, configDir(o.home))
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err == nil {
+			err = pfs.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	po.profile = name
+	po.snapshotFile = profileFile(o.snapshotFile, name)
+	po.blocklistFile = profileFile(o.blocklistFile, name)
+	for setting, value := range settings {
+		s, err := configValue(value)
+		if err == nil {
+			err = pfs.Set(setting, expandHome(s, pfs.Lookup(setting), o.home))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for '%s' in profile %s: %v", setting, name, err)
+		}
+	}
+	return po, nil
+}
+
+// profileFile returns path with the profile name added before its
+// extension, e.g. index-work.json
+func profileFile(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
+
+// reloadConfig resets every setting which wasn't given on the command line
+// to its default and applies the config file and environment again
+func reloadConfig(fs *flag.FlagSet, o *options) error {
+	sources := make(map[string]string)
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		switch {
+		case err != nil || notSettings[f.Name]:
+		case o.sources[f.Name] == "flag":
+			sources[f.Name] = "flag"
+		default:
+			err = f.Value.Set(f.DefValue)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	profiles, err := applyConfig(fs, o.configFile, o.home, o.configRequired, sources)
+	if err != nil {
+		return err
+	}
+	o.sources = sources
+	o.profiles = profiles
+	return nil
+}
+
+// configValue converts a value decoded from the config file into the string
+// form accepted by the matching flag. Arrays become comma-separated lists
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for n, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[n] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// expandHome replaces a leading '~' in each comma-separated element of a
+// string setting with home
+func expandHome(value string, f *flag.Flag, home string) string {
+	if f.Value.Type() != "string" {
+		return value
+	}
+	items := strings.Split(value, ",")
+	for n, item := range items {
+		if item == "~" || strings.HasPrefix(item, "~/") {
+			items[n] = filepath.Join(home, strings.TrimPrefix(item, "~"))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// showConfig writes the effective value of every setting to w in the config
+// file format, noting where each one came from
+func showConfig(w io.Writer, fs *flag.FlagSet, sources map[string]string) {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !notSettings[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Lookup(name)
+		value := f.Value.String()
+		switch f.Value.Type() {
+		case "string", "duration":
+			value = fmt.Sprintf("%q", value)
+		}
+		source := sources[name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%s = %s # %s\n", name, value, source)
+	}
+}
+
+// splitList returns the non-empty elements of a comma-separated setting
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
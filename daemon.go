@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	startTimeout = 5 * time.Second
+	stopTimeout  = 10 * time.Second
+	// clientOnly are the settings which only affect how a client talks to
+	// the server, so they aren't passed on to the daemon
+	clientOnly = map[string]bool{
+		"autostart":         true,
+		"autostart-timeout": true,
+		"fallback":          true,
+		"fallback-timeout":  true,
+		"profile":           true,
+		"reindex-timeout":   true,
+		"retries":           true,
+		"retry-backoff":     true,
+		"timeout":           true,
+	}
+)
+
+// serverCommand runs one of the 'ceedee server' subcommands
+func serverCommand(o *options, cmd string) {
+	var err error
+	switch cmd {
+	case "run":
+		err = runForeground(o)
+	case "start":
+		err = startAndReport(o)
+	case "stop":
+		err = stopDaemon(o)
+	case "restart":
+		if err = stopDaemon(o); err == nil {
+			err = startAndReport(o)
+		}
+	case "status":
+		err = daemonStatus(o)
+	case "reload":
+		err = reloadDaemon(o)
+	default:
+		err = fmt.Errorf("unknown server command '%s'", cmd)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runForeground records the current pid in the pid file and runs the
+// server until it exits
+func runForeground(o *options) error {
+	if pid, ok := readPidFile(o.pidFile); ok && pid != os.Getpid() {
+		return fmt.Errorf("ceedee is already running with pid %d", pid)
+	}
+	if err := writePidFile(o.pidFile); err != nil {
+		return fmt.Errorf("unable to write pid file: %v", err)
+	}
+	defer os.Remove(o.pidFile)
+	runServer(o)
+	return nil
+}
+
+// startAndReport starts the daemon and reports its pid
+func startAndReport(o *options) error {
+	pid, err := startDaemon(o)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started ceedee in daemon mode with pid %d, logging to %s\n", pid, o.logFile)
+	return nil
+}
+
+// startDaemon re-executes ceedee as 'ceedee server run' in a new session
+// with its output sent to the log file and waits for it to write its pid
+func startDaemon(o *options) (int, error) {
+	if pid, ok := readPidFile(o.pidFile); ok {
+		return 0, fmt.Errorf("ceedee is already running with pid %d", pid)
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("unable to find the ceedee binary: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(o.logFile), 0700); err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(o.logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open log file: %v", err)
+	}
+	defer logFile.Close()
+	cmd := exec.Command(binary, append([]string{"server", "run"}, daemonArgs(o)...)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("unable to start ceedee in daemon mode: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	deadline := time.After(startTimeout)
+	for {
+		if pid, ok := readPidFile(o.pidFile); ok && pid == cmd.Process.Pid {
+			return pid, nil
+		}
+		select {
+		case <-exited:
+			return 0, fmt.Errorf("ceedee exited during startup, see %s for details", o.logFile)
+		case <-deadline:
+			return 0, fmt.Errorf("ceedee did not write its pid file within %s", startTimeout)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// autoStart launches the daemon on behalf of a client which found no
+// server listening and waits until it answers. A lock file ensures that
+// only one client spawns the daemon when several start at the same time;
+// the others wait for the lock and then for the daemon to become ready
+func autoStart(o *options) error {
+	lock, err := lockFile(filepath.Join(filepath.Dir(o.pidFile), "ceedee.lock"), o.autoStartTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if _, ok := readPidFile(o.pidFile); !ok {
+		if o.root == "" {
+			flag.Set("root", o.home)
+		}
+		pid, err := startDaemon(o)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Started ceedee in daemon mode with pid %d\n", pid)
+	}
+	deadline := time.Now().Add(o.autoStartTimeout)
+	for {
+		c, _, err := newClient(o)
+		if err == nil {
+			_, err = c.Status(context.Background())
+			c.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("ceedee did not become ready within %s: %v", o.autoStartTimeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// lockFile takes an exclusive lock on path, waiting up to timeout for
+// another process to release it. Closing the returned file releases the
+// lock
+func lockFile(path string, timeout time.Duration) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return f, nil
+		}
+		if err != syscall.EWOULDBLOCK || time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// stopDaemon sends SIGTERM to the running daemon and waits for it to exit
+func stopDaemon(o *options) error {
+	pid, ok := readPidFile(o.pidFile)
+	if !ok {
+		fmt.Println("ceedee is not running")
+		return nil
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("unable to stop pid %d: %v", pid, err)
+	}
+	deadline := time.Now().Add(stopTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("ceedee (pid %d) did not stop within %s", pid, stopTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	os.Remove(o.pidFile)
+	fmt.Printf("Stopped ceedee (pid %d)\n", pid)
+	return nil
+}
+
+// daemonStatus reports whether the daemon is running along with its
+// version and uptime
+func daemonStatus(o *options) error {
+	pid, ok := readPidFile(o.pidFile)
+	if !ok {
+		fmt.Println("ceedee is not running")
+		os.Exit(3)
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	status, err := c.Status(context.Background())
+	if err != nil {
+		fmt.Printf("ceedee is running with pid %d but is not responding: %v\n", pid, err)
+		os.Exit(1)
+	}
+	uptime := time.Since(time.Unix(status.StartTime, 0)).Round(time.Second)
+	fmt.Printf("ceedee %s is running with pid %d, up %s\n", status.Version, status.Pid, uptime)
+	if status.Indexing {
+		fmt.Println("The index is still being built")
+	}
+	for _, w := range status.Walks {
+		elapsed := time.Since(time.Unix(w.StartTime, 0)).Round(time.Second)
+		fmt.Printf("Walking %s: %d directories in %s\n", w.Root, w.Dirs, elapsed)
+	}
+	return nil
+}
+
+// reloadDaemon asks the running server to re-read its configuration and
+// reports what changed
+func reloadDaemon(o *options) error {
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	changes, err := c.Reload(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to reload: %v", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Reloaded ceedee, nothing changed")
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return nil
+}
+
+// daemonArgs returns the settings which were set on the command line so
+// they can be passed on to the daemon, along with the config file it should
+// read. Settings from the config file and the environment are left for the
+// daemon to pick up itself, and flags which select what the client does or
+// only affect the client are dropped
+func daemonArgs(o *options) []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if (notSettings[f.Name] && f.Name != "config") || clientOnly[f.Name] {
+			return
+		}
+		if source := o.sources[f.Name]; source == "file" || source == "env" {
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value))
+	})
+	return args
+}
+
+// readPidFile returns the pid stored in path and whether that process is
+// still alive. A stale pid file is removed
+func readPidFile(path string) (int, bool) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || !processAlive(pid) {
+		log.Debugln("Removing stale pid file", path)
+		os.Remove(path)
+		return 0, false
+	}
+	return pid, true
+}
+
+// writePidFile stores the current pid in path
+func writePidFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
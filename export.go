@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/walkert/ceedee/ceedeeproto"
+)
+
+// exportEntry is the JSON form of an exported index entry
+type exportEntry struct {
+	Path      string   `json:"path"`
+	Source    string   `json:"source"`
+	Count     int32    `json:"count"`
+	Visits    int32    `json:"visits"`
+	LastVisit string   `json:"last_visit,omitempty"`
+	Depth     int32    `json:"depth"`
+	Score     float64  `json:"score"`
+	Tags      []string `json:"tags,omitempty"`
+	Project   bool     `json:"project,omitempty"`
+}
+
+// csvHeader names the columns written by the csv export format
+var csvHeader = []string{"path", "source", "count", "visits", "last_visit", "depth", "score", "tags", "project"}
+
+// lastVisit formats the last visit of e, which is empty if it has never
+// been visited
+func lastVisit(e *pb.Entry) string {
+	if e.LastVisit == 0 {
+		return ""
+	}
+	return time.Unix(e.LastVisit, 0).UTC().Format(time.RFC3339)
+}
+
+// exportIndex writes every entry in the server's index to w in format,
+// which is one of json, csv or z
+func exportIndex(o *options, w io.Writer, format string) error {
+	out := bufio.NewWriter(w)
+	var write func(*pb.Entry) error
+	var finish func() error
+	switch format {
+	case "json":
+		// Entries are written as they arrive rather than encoding a slice
+		// so that large indexes needn't be held in memory
+		sep := "[\n"
+		write = func(e *pb.Entry) error {
+			b, err := json.Marshal(exportEntry{
+				Path:      e.Path,
+				Source:    e.Source,
+				Count:     e.Count,
+				Visits:    e.Visits,
+				LastVisit: lastVisit(e),
+				Depth:     e.Depth,
+				Score:     e.Score,
+				Tags:      e.Tags,
+				Project:   e.Project,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(out, sep)
+			sep = ",\n"
+			_, err = out.Write(b)
+			return err
+		}
+		finish = func() error {
+			if sep == "[\n" {
+				_, err := fmt.Fprintln(out, "[]")
+				return err
+			}
+			_, err := fmt.Fprint(out, "\n]\n")
+			return err
+		}
+	case "csv":
+		cw := csv.NewWriter(out)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		write = func(e *pb.Entry) error {
+			return cw.Write([]string{
+				e.Path,
+				e.Source,
+				strconv.Itoa(int(e.Count)),
+				strconv.Itoa(int(e.Visits)),
+				lastVisit(e),
+				strconv.Itoa(int(e.Depth)),
+				strconv.FormatFloat(e.Score, 'f', -1, 64),
+				strings.Join(e.Tags, " "),
+				strconv.FormatBool(e.Project),
+			})
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "z":
+		// z's data file holds path|rank|time for directories which have
+		// been visited, so paths which have only been walked are left out
+		write = func(e *pb.Entry) error {
+			rank := e.Count + e.Visits
+			if rank == 0 {
+				return nil
+			}
+			_, err := fmt.Fprintf(out, "%s|%d|%d\n", e.Path, rank, e.LastVisit)
+			return err
+		}
+		finish = func() error { return nil }
+	default:
+		return fmt.Errorf("unknown export format '%s', expected json, csv or z", format)
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.ListFunc(context.Background(), write); err != nil {
+		return fmt.Errorf("unable to list the index: %v", err)
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/walkert/ceedee/index"
+	"github.com/walkert/ceedee/server"
+)
+
+const (
+	fallbackMaxDepth = 6
+	fallbackMaxDirs  = 50000
+)
+
+// fallbackLookup answers a query without the daemon. It uses the snapshot
+// that the daemon last saved when there is one, otherwise it walks the
+// current directory and the root within a time budget and reads the
+// history file
+func fallbackLookup(o *options, name string) []string {
+	cwd, _ := os.Getwd()
+	idx, err := index.RestoreFile(o.snapshotFile, index.WithWeights(o.weights))
+	if err == nil {
+		log.Debugln("Answering from the snapshot in", o.snapshotFile)
+		return server.EncodeResults(idx.QueryFrom(name, cwd))
+	}
+	log.Debugf("No usable snapshot (%v), walking instead\n", err)
+	var roots []string
+	if cwd != "" {
+		roots = append(roots, cwd)
+	}
+	roots = append(roots, splitList(o.root)...)
+	idx = index.New(index.WithHome(o.home), index.WithWeights(o.weights))
+	for _, root := range roots {
+		stats, err := idx.AddRoot(root, index.WalkOptions{
+			Skip:           splitList(o.skipDirs),
+			Ignore:         splitList(o.ignore),
+			Markers:        splitList(o.projectMarkers),
+			MaxDepth:       fallbackMaxDepth,
+			MaxDirs:        fallbackMaxDirs,
+			Timeout:        o.fallbackTimeout / time.Duration(len(roots)),
+			OneFileSystem:  true,
+			FollowSymlinks: o.followSymlinks,
+			CanonicalPaths: o.pathStyle == "canonical",
+		})
+		if err != nil {
+			log.Debugf("Unable to walk %s: %v\n", root, err)
+			continue
+		}
+		log.Debugf("Walked %d directories below %s in %s\n", stats.Dirs, root, stats.Duration)
+	}
+	if f, err := os.Open(o.histFile); err == nil {
+		idx.IngestHistory(f)
+		f.Close()
+	}
+	return server.EncodeResults(idx.QueryFrom(name, cwd))
+}
@@ -3,12 +3,12 @@ module github.com/walkert/ceedee
 go 1.12
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/golang/protobuf v1.3.2
 	github.com/karrick/godirwalk v1.16.1
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/pflag v1.0.3
-	github.com/walkert/watcher v0.0.0-20190723203228-83a8b05bdb6b
 	golang.org/x/text v0.3.8 // indirect
 	google.golang.org/genproto v0.0.0-20190716160619-c506a9f90610 // indirect
 	google.golang.org/grpc v1.22.0
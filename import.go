@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pb "github.com/walkert/ceedee/ceedeeproto"
+	"github.com/walkert/ceedee/index"
+)
+
+// dataDir returns the directory other tools keep their data in
+func dataDir(home string) string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(home, ".local", "share")
+}
+
+// defaultDatabase returns where the directory jumper named format keeps its
+// database by default
+func defaultDatabase(format, home string) (string, error) {
+	switch format {
+	case "z":
+		if path := os.Getenv("_Z_DATA"); path != "" {
+			return path, nil
+		}
+		return filepath.Join(home, ".z"), nil
+	case "fasd":
+		if path := os.Getenv("_FASD_DATA"); path != "" {
+			return path, nil
+		}
+		return filepath.Join(home, ".fasd"), nil
+	case "autojump":
+		return filepath.Join(dataDir(home), "autojump", "autojump.txt"), nil
+	case "zoxide":
+		if dir := os.Getenv("_ZO_DATA_DIR"); dir != "" {
+			return filepath.Join(dir, "db.zo"), nil
+		}
+		return filepath.Join(dataDir(home), "zoxide", "db.zo"), nil
+	default:
+		return "", fmt.Errorf("unknown database format '%s', expected z, autojump, fasd or zoxide", format)
+	}
+}
+
+// importDatabase reads the database of the directory jumper named format
+// from path, or from its default location if path is empty, and sends it
+// to the server to merge into the history ranks
+func importDatabase(o *options, format, path string) error {
+	if path == "" {
+		var err error
+		if path, err = defaultDatabase(format, o.home); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	imported, err := index.ParseDatabase(format, f)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	entries := make([]*pb.ImportEntry, len(imported))
+	for n, e := range imported {
+		entries[n] = &pb.ImportEntry{Path: e.Path, Rank: e.Rank}
+		if !e.LastVisit.IsZero() {
+			entries[n].LastVisit = e.LastVisit.Unix()
+		}
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	reply, err := c.Import(context.Background(), entries)
+	if err != nil {
+		return fmt.Errorf("unable to import %s: %v", path, err)
+	}
+	fmt.Printf("Imported %d directories from %s, skipping %d which no longer exist\n", reply.Imported, path, reply.Missing)
+	return nil
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// blockedBy returns the first pattern in patterns which matches path or
+// one of the directories above it
+func blockedBy(patterns []string, path string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return pattern, true
+			}
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return "", false
+		}
+	}
+}
+
+// SetBlocklist replaces the patterns, as understood by filepath.Match, of
+// paths which are never indexed. A path is blocked when it or a directory
+// above it matches one of the patterns. Blocked paths aren't walked or
+// added from the history or visits, and any already in the index are
+// removed along with their history. It returns the number of paths removed
+func (i *Index) SetBlocklist(patterns []string) (int, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return 0, fmt.Errorf("invalid blocklist pattern %q: %v", pattern, err)
+		}
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.blocklist = append([]string(nil), patterns...)
+	removed := i.forgetWhere(func(path string) bool {
+		_, ok := blockedBy(i.blocklist, path)
+		return ok
+	})
+	if removed > 0 {
+		log.Debugf("Removed %d blocked paths\n", removed)
+	}
+	return removed, nil
+}
+
+// Blocklist returns the patterns of paths which are never indexed
+func (i *Index) Blocklist() []string {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return append([]string(nil), i.blocklist...)
+}
@@ -0,0 +1,56 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Bookmark is a short name for a directory
+type Bookmark struct {
+	Name string
+	Path string
+}
+
+// Mark records name as a bookmark for path, replacing any bookmark of the
+// same name. Queries for name return path before any other match. path must
+// be absolute
+func (i *Index) Mark(name, path string) error {
+	if name == "" || strings.ContainsAny(name, "/:;@") {
+		return fmt.Errorf("invalid bookmark name '%s'", name)
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("bookmarked path %s is not absolute", path)
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.marks[name] = filepath.Clean(path)
+	log.Debugf("Bookmarked %s as %s\n", path, name)
+	return nil
+}
+
+// Unmark removes the bookmark name and reports whether there was one
+func (i *Index) Unmark(name string) bool {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	_, ok := i.marks[name]
+	delete(i.marks, name)
+	return ok
+}
+
+// Marks returns every bookmark, sorted by name
+func (i *Index) Marks() []Bookmark {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	marks := make([]Bookmark, 0, len(i.marks))
+	for name, path := range i.marks {
+		marks = append(marks, Bookmark{Name: name, Path: path})
+	}
+	sort.Slice(marks, func(a, b int) bool {
+		return marks[a].Name < marks[b].Name
+	})
+	return marks
+}
@@ -0,0 +1,39 @@
+/*
+Package index ranks the directories on a machine so that a short name can be
+turned into the full path the user most likely wants.
+
+An Index maps each directory basename ('src') to every full path with that
+basename ('/home/user/src', '/home/user/work/api/src'). Paths come from three
+places:
+
+  - Walking one or more roots with AddRoot. Walked paths rank by how close
+    they are to the filesystem root.
+  - Shell history fed to IngestHistory. Every 'cd /some/path' or 'cd ~/path'
+    adds to the rank of that path.
+  - Explicit visits recorded with Visit, which rank like history.
+
+With the DefaultWeights, a path from the history or visits outranks one
+which has only been walked, even a project root. QueryFrom also ranks paths
+by how close they are to the directory the query was made from, so a walked
+path in the same repository can outrank one from the history. Query and
+QueryFrom look a name up. An exact basename match returns the ranked full
+paths; otherwise every basename containing the name is returned as a
+partial match.
+
+Snapshot and Restore save and load the whole index, so an embedding program
+can persist its ranking across restarts without running the ceedee daemon:
+
+	idx := index.New(index.WithHome(home))
+	if _, err := idx.AddRoot(home, index.WalkOptions{Skip: []string{".git"}}); err != nil {
+		return err
+	}
+	f, _ := os.Open(filepath.Join(home, ".zhistfile"))
+	idx.IngestHistory(f)
+	idx.Visit("/home/user/work/api/src")
+	for _, r := range idx.Query("src") {
+		fmt.Println(r.Path)
+	}
+
+An Index is safe for concurrent use.
+*/
+package index
@@ -0,0 +1,24 @@
+package index_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/walkert/ceedee/index"
+)
+
+func Example() {
+	idx := index.New(index.WithHome("/home/user"))
+	if _, err := idx.AddRoot("../testdata", index.WalkOptions{Skip: []string{"ignore"}}); err != nil {
+		panic(err)
+	}
+	history := strings.NewReader("cd ~/src/foo\n: 1563902400:0;cd /srv/foo\ncd /srv/foo\n")
+	idx.IngestHistory(history)
+	for _, r := range idx.Query("foo") {
+		fmt.Println(r.Path)
+	}
+	// Output:
+	// /srv/foo
+	// /home/user/src/foo
+	// ../testdata/foo
+}
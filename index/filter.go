@@ -0,0 +1,89 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// exclusion says why a walk leaves a directory out
+type exclusion int
+
+const (
+	included exclusion = iota
+	// tooDeep is a directory beyond the walk's maximum depth
+	tooDeep
+	// otherFilesystem is a directory on a filesystem other than the root's
+	otherFilesystem
+	// skipped, blocked and ignored are directories matching an entry of
+	// the skip list, the blocklist or the ignore patterns
+	skipped
+	blocked
+	ignored
+)
+
+// filter decides which directories a walk of a root leaves out. Probe uses
+// the same filter as the walk so that it never reaches a directory the walk
+// wouldn't
+type filter struct {
+	root    string
+	opts    WalkOptions
+	skip    map[string]struct{}
+	block   []string
+	rootDev uint64
+}
+
+// newFilter returns the filter for a walk of root with opts while the
+// blocklist holds block
+func newFilter(root string, opts WalkOptions, block []string) (*filter, error) {
+	f := &filter{
+		root:  filepath.Clean(root),
+		opts:  opts,
+		skip:  make(map[string]struct{}),
+		block: append([]string(nil), block...),
+	}
+	for _, s := range opts.Skip {
+		f.skip[s] = struct{}{}
+	}
+	for _, pattern := range opts.Ignore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	if opts.OneFileSystem {
+		id, err := statID(f.root)
+		if err != nil {
+			return nil, err
+		}
+		f.rootDev = id.dev
+	}
+	return f, nil
+}
+
+// excludes reports whether the walk leaves out path, and why. For the skip
+// list, blocklist and ignore patterns it also returns the entry which
+// matched. id identifies path and is only looked at with OneFileSystem
+func (f *filter) excludes(path string, id fileID) (exclusion, string) {
+	if f.opts.MaxDepth > 0 && relativeDepth(f.root, path) > f.opts.MaxDepth {
+		return tooDeep, ""
+	}
+	if f.opts.OneFileSystem && id.dev != f.rootDev {
+		return otherFilesystem, ""
+	}
+	for _, rule := range []string{filepath.Base(path), path} {
+		if _, ok := f.skip[rule]; ok {
+			return skipped, rule
+		}
+	}
+	if pattern, ok := blockedBy(f.block, path); ok {
+		return blocked, pattern
+	}
+	for _, pattern := range f.opts.Ignore {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return ignored, pattern
+		}
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return ignored, pattern
+		}
+	}
+	return included, ""
+}
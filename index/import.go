@@ -0,0 +1,227 @@
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// zoxideVersion is the only zoxide database version which is understood
+const zoxideVersion = 3
+
+// minImportTop is the history count given to the highest ranked imported
+// path when the index has no history of its own to scale against
+const minImportTop = 10
+
+// Imported is a path read from another directory jumper's database
+type Imported struct {
+	Path string
+	// Rank is the path's weight in the database it came from. Ranks are
+	// only comparable within a single database
+	Rank      float64
+	LastVisit time.Time
+}
+
+// ParseDatabase reads the database of another directory jumper from r.
+// format is one of "z" or "fasd" (path|rank|time lines), "autojump"
+// (weight<tab>path lines) or "zoxide" (its binary database)
+func ParseDatabase(format string, r io.Reader) ([]Imported, error) {
+	switch format {
+	case "z", "fasd":
+		return parseZ(r)
+	case "autojump":
+		return parseAutojump(r)
+	case "zoxide":
+		return parseZoxide(r)
+	default:
+		return nil, fmt.Errorf("unknown database format '%s'", format)
+	}
+}
+
+// parseZ reads the path|rank|time lines used by z and fasd
+func parseZ(r io.Reader) ([]Imported, error) {
+	var entries []Imported
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// The path may itself contain '|' so split from the right
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected path|rank|time", n)
+		}
+		path := strings.Join(fields[:len(fields)-2], "|")
+		rank, err := strconv.ParseFloat(fields[len(fields)-2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rank: %v", n, err)
+		}
+		secs, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time: %v", n, err)
+		}
+		entries = append(entries, Imported{Path: path, Rank: rank, LastVisit: unixTime(secs)})
+	}
+	return entries, scanner.Err()
+}
+
+// parseAutojump reads the weight<tab>path lines used by autojump
+func parseAutojump(r io.Reader) ([]Imported, error) {
+	var entries []Imported
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected weight<tab>path", n)
+		}
+		rank, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid weight: %v", n, err)
+		}
+		entries = append(entries, Imported{Path: fields[1], Rank: rank})
+	}
+	return entries, scanner.Err()
+}
+
+// parseZoxide reads a zoxide database. It is bincode encoded: a
+// little-endian u32 version followed by a u64 count of directories, each
+// of which is a u64 length and the bytes of its path, an f64 rank and the
+// u64 unix time it was last accessed
+func parseZoxide(r io.Reader) ([]Imported, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	d := &decoder{b: b}
+	if version := d.uint32(); d.err == nil && version != zoxideVersion {
+		return nil, fmt.Errorf("unsupported zoxide database version %d", version)
+	}
+	count := d.uint64()
+	var entries []Imported
+	for n := uint64(0); n < count && d.err == nil; n++ {
+		path := d.string()
+		rank := math.Float64frombits(d.uint64())
+		secs := d.uint64()
+		if d.err == nil {
+			entries = append(entries, Imported{Path: path, Rank: rank, LastVisit: unixTime(int64(secs))})
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid zoxide database: %v", d.err)
+	}
+	return entries, nil
+}
+
+// decoder reads little-endian fixed width values from b, recording the
+// first error
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// next returns the next n bytes
+func (d *decoder) next(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.b)) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// uint32 returns the next u32
+func (d *decoder) uint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// uint64 returns the next u64
+func (d *decoder) uint64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// string returns the next length-prefixed string
+func (d *decoder) string() string {
+	return string(d.next(d.uint64()))
+}
+
+// unixTime converts secs to a time, leaving 0 as the zero time
+func unixTime(secs int64) time.Time {
+	if secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// Import merges paths from another directory jumper's database into the
+// history ranks. Ranks are scaled so that the highest ranked imported path
+// counts as much as the highest ranked path already in the index, or
+// minImportTop if there is no history yet, and every imported path counts
+// at least once. Paths are added even if no walk has found them, except
+// for blocked paths. It returns the number of paths imported
+func (i *Index) Import(entries []Imported) int {
+	var maxRank float64
+	for _, e := range entries {
+		maxRank = math.Max(maxRank, e.Rank)
+	}
+	if maxRank <= 0 {
+		return 0
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	top := minImportTop
+	for _, d := range i.dirs {
+		for _, h := range d.histCandidates {
+			if h.count+h.visits > top {
+				top = h.count + h.visits
+			}
+		}
+	}
+	imported := 0
+	for _, e := range entries {
+		if e.Rank <= 0 || !filepath.IsAbs(e.Path) {
+			continue
+		}
+		path := filepath.Clean(e.Path)
+		if _, ok := blockedBy(i.blocklist, path); ok {
+			continue
+		}
+		count := int(math.Ceil(e.Rank / maxRank * float64(top)))
+		base := filepath.Base(path)
+		d, ok := i.dirs[base]
+		if !ok {
+			d = &directory{path: base, tracker: make(map[string]struct{})}
+			i.dirs[base] = d
+		}
+		d.addHistCandidate(path, count, 0, e.LastVisit)
+		imported++
+	}
+	log.Debugf("Imported %d of %d paths scaled to a top count of %d\n", imported, len(entries), top)
+	return imported
+}
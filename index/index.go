@@ -0,0 +1,1071 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const snapshotVersion = 1
+
+var (
+	cdpath = regexp.MustCompile(`cd\s+([~\/]\/?.*[^\/]$)`)
+)
+
+type directory struct {
+	path           string
+	histCandidates []candidate
+	pathCandidates []candidate
+	tracker        map[string]struct{}
+	// tags maps paths to their sorted tags
+	tags map[string][]string
+}
+
+// empty reports whether the directory has no paths or tags left
+func (d *directory) empty() bool {
+	return len(d.pathCandidates) == 0 && len(d.histCandidates) == 0 && len(d.tags) == 0
+}
+
+// candidate returns the candidate for path, preferring its history entry,
+// or a bare candidate if path has only been tagged. Whether it is a project
+// root is only recorded on its walked entry
+func (d *directory) candidate(path string) candidate {
+	for _, list := range [][]candidate{d.histCandidates, d.pathCandidates} {
+		for _, c := range list {
+			if c.path == path {
+				return c
+			}
+		}
+	}
+	return candidate{path: path, depth: len(strings.Split(path, "/"))}
+}
+
+// addPathCandidate creates a new pathCandidates entry and sorts the list
+// according to the directory depth. It reports whether path was new
+func (d *directory) addPathCandidate(path string) bool {
+	if _, ok := d.tracker[path]; ok {
+		return false
+	}
+	log.Debugf("Adding a new candidate path %s to base %s\n", path, d.path)
+	d.tracker[path] = struct{}{}
+	c := candidate{path: path, depth: len(strings.Split(path, "/"))}
+	d.pathCandidates = append(d.pathCandidates, c)
+	sort.Slice(d.pathCandidates, func(i, j int) bool {
+		if d.pathCandidates[i].depth < d.pathCandidates[j].depth {
+			return true
+		}
+		return false
+	})
+	return true
+}
+
+// addHistCandidate creates a new histCandidates entry, or adds count and
+// visits to an existing one, and sorts the list by how many times each
+// entry has appeared in the history or been visited. count is the number of
+// new history lines for path, so the whole of it is added rather than one.
+// A non-zero visited time is recorded as the entry's last visit
+func (d *directory) addHistCandidate(path string, count, visits int, visited time.Time) {
+	var exists bool
+	for idx, c := range d.histCandidates {
+		if c.path == path {
+			d.histCandidates[idx].count += count
+			d.histCandidates[idx].visits += visits
+			if visited.After(c.lastVisit) {
+				d.histCandidates[idx].lastVisit = visited
+			}
+			exists = true
+		}
+	}
+	if !exists {
+		c := candidate{path: path, count: count, depth: len(strings.Split(path, "/")), visits: visits, lastVisit: visited}
+		d.histCandidates = append(d.histCandidates, c)
+	}
+	sort.Slice(d.histCandidates, func(i, j int) bool {
+		if d.histCandidates[i].count+d.histCandidates[i].visits > d.histCandidates[j].count+d.histCandidates[j].visits {
+			return true
+		}
+		return false
+	})
+}
+
+// candidateList returns every path for the directory, ordered by their
+// score under w from the vantage v. A path which is both walked and in the
+// history is only listed once
+func (d *directory) candidateList(w Weights, v vantage) []Result {
+	var ranked []candidate
+	seen := make(map[string]struct{})
+	projects := d.projects()
+	for _, list := range [][]candidate{d.histCandidates, d.pathCandidates} {
+		for _, c := range list {
+			if _, ok := seen[c.path]; ok {
+				continue
+			}
+			seen[c.path] = struct{}{}
+			c.project = projects[c.path]
+			ranked = append(ranked, c)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score(w)+v.boost(w, ranked[i].path) > ranked[j].score(w)+v.boost(w, ranked[j].path)
+	})
+	explain := log.IsLevelEnabled(log.DebugLevel)
+	list := make([]Result, len(ranked))
+	for n, c := range ranked {
+		list[n] = Result{Path: c.path, Match: Exact}
+		if explain {
+			log.Debugf("Ranked %s at %s\n", c.path, v.explain(w, c))
+		}
+	}
+	return list
+}
+
+type candidate struct {
+	count     int
+	depth     int
+	lastVisit time.Time
+	path      string
+	visits    int
+	// project is set when the walk found a project marker in the path
+	project bool
+}
+
+// score ranks the candidate under w. History, visits and being a project
+// root raise the score while every level of depth lowers it
+func (c candidate) score(w Weights) float64 {
+	s := w.History*float64(c.count) + w.Visit*float64(c.visits) - w.Depth*float64(c.depth)
+	if c.project {
+		s += w.Project
+	}
+	return s
+}
+
+// Weights controls how exact matches for a name are ranked against each
+// other. A path scores History for every 'cd' to it found in the history,
+// Visit for every recorded visit and loses Depth for each path element.
+// A project root, holding one of the walk's project markers, scores
+// Project. When the query says where it was made from, a path also scores
+// Proximity for each leading path element it shares with that directory and
+// Repo if it is in the same repository
+type Weights struct {
+	History   float64
+	Visit     float64
+	Depth     float64
+	Proximity float64
+	Repo      float64
+	Project   float64
+}
+
+// DefaultWeights ranks a path from the history or visits above a path which
+// has only been walked, project roots included, and shallower paths above
+// deeper ones, as long as the query doesn't say where it was made from.
+// When it does, being in the same repository counts as much as a visit and
+// each shared path element a tenth of one, so a nearby walked path can
+// outrank one from the history
+var DefaultWeights = Weights{History: 100, Visit: 100, Depth: 1, Proximity: 10, Repo: 100, Project: 50}
+
+// Match describes how a Result matched a query
+type Match int
+
+const (
+	// Exact means the basename of Path is the name that was queried
+	Exact Match = iota
+	// Partial means Path is a basename which contains the name that was
+	// queried
+	Partial
+	// Bookmarked means Path was bookmarked as the name that was queried
+	Bookmarked
+)
+
+// Result is a single answer to a query
+type Result struct {
+	Path  string
+	Match Match
+}
+
+// root is a directory which is walked whenever the index is refreshed
+type root struct {
+	path string
+	opts WalkOptions
+	// walked is when the last walk of the root started
+	walked time.Time
+}
+
+// Index holds every known directory keyed by its basename
+type Index struct {
+	mux     sync.Mutex
+	dirs    map[string]*directory
+	home    string
+	roots   []root
+	walking map[*walker]struct{}
+	weights Weights
+	// history counts the lines read by IngestHistory and the 'cd' commands
+	// in them which couldn't be understood
+	historyLines    int
+	historyFailures int
+	// blocklist holds patterns of paths which are never indexed
+	blocklist []string
+	// marks maps bookmark names to paths
+	marks map[string]string
+}
+
+// Opt defines a functional option that operates on an Index
+type Opt func(i *Index)
+
+// WithHome sets the home directory used to expand '~' in history entries
+func WithHome(home string) Opt {
+	return func(i *Index) {
+		i.home = home
+	}
+}
+
+// WithWeights sets the weights used to rank exact matches
+func WithWeights(w Weights) Opt {
+	return func(i *Index) {
+		i.weights = w
+	}
+}
+
+// New returns an empty Index
+func New(opts ...Opt) *Index {
+	i := &Index{
+		dirs:    make(map[string]*directory),
+		marks:   make(map[string]string),
+		walking: make(map[*walker]struct{}),
+		weights: DefaultWeights,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// AddRoot walks root and remembers it so that Refresh walks it again
+func (i *Index) AddRoot(path string, opts WalkOptions) (WalkStats, error) {
+	return i.AddRootContext(context.Background(), path, opts)
+}
+
+// AddRootContext is like AddRoot but stops the walk early if ctx is
+// canceled. The directories found so far are kept. The root is only
+// remembered once it has been walked without an error, and adding a root
+// which is already known walks it again with opts in its place. Once a walk
+// completes, directories below root which are already in the index, such
+// as those restored from a snapshot, but weren't found again are removed,
+// unless they are also below another root
+func (i *Index) AddRootContext(ctx context.Context, path string, opts WalkOptions) (WalkStats, error) {
+	w, err := i.scan(ctx, path, path, opts, true)
+	if err != nil {
+		return w.stats, err
+	}
+	clean := filepath.Clean(path)
+	i.mux.Lock()
+	known := false
+	for n := range i.roots {
+		if filepath.Clean(i.roots[n].path) == clean {
+			i.roots[n].opts, i.roots[n].walked = opts, w.stats.Start
+			known = true
+		}
+	}
+	if !known {
+		i.roots = append(i.roots, root{path: path, opts: opts, walked: w.stats.Start})
+	}
+	i.mux.Unlock()
+	if w.halted {
+		return w.stats, nil
+	}
+	i.mux.Lock()
+	removed := i.prune(w.found, w.unreadable, func(p string) bool {
+		if !under(p, clean) {
+			return false
+		}
+		for _, r := range i.roots {
+			if rp := filepath.Clean(r.path); rp != clean && under(p, rp) {
+				return false
+			}
+		}
+		return true
+	})
+	i.mux.Unlock()
+	if removed > 0 {
+		log.Infof("Removed %d directories below %s which no longer exist\n", removed, path)
+	}
+	return w.stats, nil
+}
+
+// markWalked records that a walk of root started at start
+func (i *Index) markWalked(path string, start time.Time) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	for n := range i.roots {
+		if i.roots[n].path == path {
+			i.roots[n].walked = start
+		}
+	}
+}
+
+// Roots returns the paths which have been added with AddRoot
+func (i *Index) Roots() []string {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	var paths []string
+	for _, r := range i.roots {
+		paths = append(paths, r.path)
+	}
+	return paths
+}
+
+// RemoveRoot stops walking root and drops every walked directory below it
+// which isn't also below one of the remaining roots. History ranks and
+// visits are kept. It returns the number of directories dropped
+func (i *Index) RemoveRoot(path string) int {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	path = filepath.Clean(path)
+	var kept []root
+	for _, r := range i.roots {
+		if filepath.Clean(r.path) != path {
+			kept = append(kept, r)
+		}
+	}
+	i.roots = kept
+	removed := i.dropWalked(func(p string) bool {
+		return under(p, path) && !i.coveredByRoot(p)
+	})
+	log.Debugf("Dropped %d directories below %s\n", removed, path)
+	return removed
+}
+
+// dropWalked removes every walked directory for which drop returns true,
+// along with any basename which is left with no paths. It returns the
+// number of directories removed. The caller must hold the lock
+func (i *Index) dropWalked(drop func(path string) bool) int {
+	removed := 0
+	for base, d := range i.dirs {
+		var paths []candidate
+		for _, c := range d.pathCandidates {
+			if !drop(c.path) {
+				paths = append(paths, c)
+				continue
+			}
+			delete(d.tracker, c.path)
+			removed++
+		}
+		d.pathCandidates = paths
+		if d.empty() {
+			delete(i.dirs, base)
+		}
+	}
+	return removed
+}
+
+// coveredByRoot reports whether path is below one of the roots. The caller
+// must hold the lock
+func (i *Index) coveredByRoot(path string) bool {
+	for _, r := range i.roots {
+		if under(path, filepath.Clean(r.path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// under reports whether path is dir or is below it
+func under(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// SetWeights changes the weights used to rank exact matches
+func (i *Index) SetWeights(w Weights) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.weights = w
+}
+
+// Refresh walks every root again, adding any directories which have been
+// created since the last walk. It returns the outcome of each walk which
+// succeeded. A root which can't be walked doesn't stop the others, but
+// the returned error lists every one which failed
+func (i *Index) Refresh() ([]WalkStats, error) {
+	return i.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh but stops early if ctx is canceled, in
+// which case it returns ctx's error
+func (i *Index) RefreshContext(ctx context.Context) ([]WalkStats, error) {
+	i.mux.Lock()
+	roots := append([]root(nil), i.roots...)
+	i.mux.Unlock()
+	var stats []WalkStats
+	var failed []string
+	for _, r := range roots {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		st, err := i.walk(ctx, r.path, r.opts)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", r.path, err))
+			continue
+		}
+		i.markWalked(r.path, st.Start)
+		stats = append(stats, st)
+	}
+	if len(failed) > 0 {
+		return stats, fmt.Errorf("unable to walk %s", strings.Join(failed, "; "))
+	}
+	return stats, nil
+}
+
+// ErrNoRoot is returned by Reindex for a path which isn't below any root
+var ErrNoRoot = errors.New("path is not below any root")
+
+// ReindexStats describes the outcome of Reindex
+type ReindexStats struct {
+	Walks []WalkStats
+	// Added and Removed count the directories which were new to the index
+	// and which no longer exist
+	Added   int
+	Removed int
+	// Truncated is set when a walk was cut short, in which case nothing
+	// was removed
+	Truncated bool
+}
+
+// Reindex walks path again straight away, or every root if path is empty,
+// and merges what it finds into the index. The walk of path follows the
+// options of each root it is below, and any root below path is walked
+// with its own options. Walked directories below path which weren't found
+// again are removed, except for those below a directory which couldn't be
+// read. History ranks and visits are kept
+func (i *Index) Reindex(ctx context.Context, path string) (ReindexStats, error) {
+	var stats ReindexStats
+	i.mux.Lock()
+	roots := append([]root(nil), i.roots...)
+	i.mux.Unlock()
+	type job struct {
+		r     root
+		start string
+	}
+	var jobs []job
+	inScope := func(p string) bool {
+		return under(p, path)
+	}
+	if path == "" {
+		for _, r := range roots {
+			jobs = append(jobs, job{r, r.path})
+		}
+		inScope = func(p string) bool {
+			for _, r := range roots {
+				if under(p, filepath.Clean(r.path)) {
+					return true
+				}
+			}
+			return false
+		}
+	} else {
+		path = filepath.Clean(path)
+		for _, r := range roots {
+			switch rp := filepath.Clean(r.path); {
+			case under(path, rp):
+				jobs = append(jobs, job{r, path})
+			case under(rp, path):
+				jobs = append(jobs, job{r, rp})
+			}
+		}
+		if len(jobs) == 0 {
+			return stats, ErrNoRoot
+		}
+	}
+	found := make(map[string]struct{})
+	var unreadable []string
+	for _, j := range jobs {
+		if _, err := os.Lstat(j.start); os.IsNotExist(err) && j.start != j.r.path {
+			log.Debugf("%s no longer exists\n", j.start)
+			continue
+		}
+		w, err := i.scan(ctx, j.r.path, j.start, j.r.opts, true)
+		stats.Walks = append(stats.Walks, w.stats)
+		if err != nil {
+			return stats, err
+		}
+		if j.start == j.r.path {
+			i.markWalked(j.r.path, w.stats.Start)
+		}
+		stats.Added += w.stats.Added
+		stats.Truncated = stats.Truncated || w.halted
+		for p := range w.found {
+			found[p] = struct{}{}
+		}
+		unreadable = append(unreadable, w.unreadable...)
+	}
+	if stats.Truncated {
+		log.Infof("Not removing any directories as the reindex of %s was cut short\n", path)
+		return stats, nil
+	}
+	i.mux.Lock()
+	stats.Removed = i.prune(found, unreadable, inScope)
+	i.mux.Unlock()
+	log.Debugf("Reindex added %d and removed %d directories\n", stats.Added, stats.Removed)
+	return stats, nil
+}
+
+// prune removes the walked directories for which inScope returns true but
+// which aren't in found, except for those below one of the unreadable
+// directories. It returns the number of directories removed. The caller
+// must hold the lock
+func (i *Index) prune(found map[string]struct{}, unreadable []string, inScope func(path string) bool) int {
+	return i.dropWalked(func(p string) bool {
+		if _, ok := found[p]; ok || !inScope(p) {
+			return false
+		}
+		for _, u := range unreadable {
+			if under(p, u) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// addPath records path as a walked directory and reports whether it was
+// new. The caller must hold the lock
+func (i *Index) addPath(path string) bool {
+	if _, ok := blockedBy(i.blocklist, path); ok {
+		return false
+	}
+	base := filepath.Base(path)
+	_, ok := i.dirs[base]
+	if !ok {
+		log.Debugln("Creating new directory reference for", base)
+		d := &directory{path: base, tracker: make(map[string]struct{})}
+		i.dirs[base] = d
+	}
+	return i.dirs[base].addPathCandidate(path)
+}
+
+// AddPath records path as a directory found by walking the filesystem
+func (i *Index) AddPath(path string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.addPath(path)
+}
+
+// IngestHistory reads shell history from r and adds every 'cd' to an
+// absolute or home-relative path to the history rank of that path. Both
+// plain and zsh extended history lines are understood
+func (i *Index) IngestHistory(r io.Reader) error {
+	var command string
+	var lines, failures int
+	pathMap := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		parts := strings.Split(scanner.Text(), ";")
+		if len(parts) == 1 {
+			command = parts[0]
+		} else {
+			command = parts[len(parts)-1]
+		}
+		match := cdpath.FindStringSubmatch(command)
+		if len(match) != 2 {
+			if fields := strings.Fields(command); len(fields) > 1 && fields[0] == "cd" {
+				log.Debugf("Unable to understand history entry: %s\n", command)
+				failures++
+			}
+			continue
+		}
+		path := match[1]
+		if strings.HasPrefix(path, "~") {
+			path = strings.Replace(path, "~", i.home, 1)
+		}
+		pathMap[path]++
+	}
+	// Now that we have our paths with the counts, see if they're already in
+	// the index and if they are, ensure that they're first in the list of options
+	// if appropriate
+	for path, count := range pathMap {
+		i.AddHistory(path, count)
+	}
+	i.mux.Lock()
+	i.historyLines += lines
+	i.historyFailures += failures
+	i.mux.Unlock()
+	return scanner.Err()
+}
+
+// AddHistory adds count to the history rank of path. Paths whose basename
+// has not been seen by a walk are ignored
+func (i *Index) AddHistory(path string, count int) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if _, ok := blockedBy(i.blocklist, path); ok {
+		return
+	}
+	base := filepath.Base(path)
+	_, ok := i.dirs[base]
+	if !ok {
+		// TODO: Run addHistCandidate here to create a new entry even if it hasn't been
+		//		 seen by the filepath walker.
+		return
+	}
+	log.Debugf("Adding/updating a hist path link %s->%s\n", base, path)
+	i.dirs[base].addHistCandidate(path, count, 0, time.Time{})
+}
+
+// Visit records that the user has just changed to path. Visits rank like
+// history entries, and path is added to the index even if no walk has
+// found it
+func (i *Index) Visit(path string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	path = filepath.Clean(path)
+	if pattern, ok := blockedBy(i.blocklist, path); ok {
+		log.Debugf("Not recording a visit to %s as it matches %s\n", path, pattern)
+		return
+	}
+	base := filepath.Base(path)
+	d, ok := i.dirs[base]
+	if !ok {
+		d = &directory{path: base, tracker: make(map[string]struct{})}
+		i.dirs[base] = d
+	}
+	log.Debugln("Recording a visit to", path)
+	d.addHistCandidate(path, 0, 1, time.Now())
+}
+
+// Stats summarises the contents of an Index
+type Stats struct {
+	// Names is the number of distinct basenames
+	Names int
+	// Paths is the number of distinct paths
+	Paths int
+	// History is the number of paths with a history rank or visits
+	History int
+	// HistoryLines is the number of lines read by IngestHistory
+	HistoryLines int
+	// HistoryFailures is the number of 'cd' commands read by IngestHistory
+	// whose target couldn't be understood, such as relative paths
+	HistoryFailures int
+}
+
+// Stats returns a summary of the index
+func (i *Index) Stats() Stats {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	st := Stats{
+		Names:           len(i.dirs),
+		HistoryLines:    i.historyLines,
+		HistoryFailures: i.historyFailures,
+	}
+	for _, d := range i.dirs {
+		st.Paths += len(d.pathCandidates)
+		st.History += len(d.histCandidates)
+		untracked := make(map[string]struct{})
+		for _, h := range d.histCandidates {
+			if _, ok := d.tracker[h.path]; !ok {
+				untracked[h.path] = struct{}{}
+			}
+		}
+		for path := range d.tags {
+			if _, ok := d.tracker[path]; !ok {
+				untracked[path] = struct{}{}
+			}
+		}
+		st.Paths += len(untracked)
+	}
+	return st
+}
+
+// Has reports whether any directory with the given basename is indexed
+func (i *Index) Has(name string) bool {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	_, ok := i.dirs[name]
+	return ok
+}
+
+// Len returns the number of distinct basenames in the index
+func (i *Index) Len() int {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return len(i.dirs)
+}
+
+func (i *Index) getPartial(name string) []Result {
+	start := time.Now()
+	var names []string
+	for path := range i.dirs {
+		if strings.Index(path, name) > -1 {
+			log.Debugln("Found a match for name:", name)
+			names = append(names, path)
+		}
+	}
+	sort.Strings(names)
+	matches := make([]Result, len(names))
+	for n, path := range names {
+		matches[n] = Result{Path: path, Match: Partial}
+	}
+	log.Debugln("Time taken to find partial:", time.Now().Sub(start))
+	return matches
+}
+
+// Query returns the ranked matches for name. A bookmark called name comes
+// first as a Bookmarked result, followed by any other exact matches. When
+// name is the basename of one or more directories their full paths are
+// returned, best first, as Exact results. Otherwise every basename
+// containing name is returned in lexical order as a Partial result.
+// Terms beginning with '@' restrict the matches to paths with those tags;
+// see ParseQuery. A name written as '^name' only matches project roots. A
+// query whose tags or '^' match nothing is tried again as a plain name, so
+// that directories such as '@types' can still be found
+func (i *Index) Query(name string) []Result {
+	return i.QueryFrom(name, "")
+}
+
+// QueryFrom is like Query for a query made from the directory cwd. Exact
+// matches near cwd, or in the same repository, rank higher as set by the
+// Proximity and Repo weights
+func (i *Index) QueryFrom(name, cwd string) []Result {
+	v := newVantage(cwd)
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	term, tags := ParseQuery(name)
+	if term, project := parseProject(term); project || len(tags) > 0 {
+		if results := i.querySelected(term, tags, project, v); len(results) > 0 {
+			return results
+		}
+	}
+	mark, marked := i.marks[name]
+	dir, ok := i.dirs[name]
+	switch {
+	case marked:
+		results := []Result{{Path: mark, Match: Bookmarked}}
+		if ok {
+			for _, r := range dir.candidateList(i.weights, v) {
+				if r.Path != mark {
+					results = append(results, r)
+				}
+			}
+		}
+		return results
+	case !ok:
+		log.Debugf("No direct match for %s, starting partial check..\n", name)
+		return i.getPartial(name)
+	}
+	return dir.candidateList(i.weights, v)
+}
+
+// Entry describes a single indexed path
+type Entry struct {
+	Path string
+	// Source is where the path's rank comes from: "visit" for a path with
+	// recorded visits, "history" for one ranked by the history or an
+	// import, "walk" or, for a path which is only known because it was
+	// tagged, "tag"
+	Source    string
+	Count     int
+	Visits    int
+	LastVisit time.Time
+	Depth     int
+	// Score is the rank of the path among others with the same basename
+	// under the index's weights
+	Score float64
+	Tags  []string
+	// Project is set for a project root
+	Project bool
+}
+
+// Entries returns every indexed path, sorted by path. A path which was
+// both walked and found in the history is returned once
+func (i *Index) Entries() []Entry {
+	i.mux.Lock()
+	byPath := make(map[string]*Entry)
+	for _, d := range i.dirs {
+		for _, p := range d.pathCandidates {
+			byPath[p.path] = &Entry{Path: p.path, Source: "walk", Depth: p.depth, Project: p.project}
+		}
+	}
+	for _, d := range i.dirs {
+		for _, h := range d.histCandidates {
+			e, ok := byPath[h.path]
+			if !ok {
+				e = &Entry{Path: h.path, Depth: len(strings.Split(h.path, "/"))}
+				byPath[h.path] = e
+			}
+			e.Count = h.count
+			e.Visits = h.visits
+			e.LastVisit = h.lastVisit
+			// Imported ranks come with the time of the last visit but
+			// are history counts rather than visits
+			e.Source = "history"
+			if h.visits > 0 {
+				e.Source = "visit"
+			}
+		}
+	}
+	for _, d := range i.dirs {
+		for path, tags := range d.tags {
+			e, ok := byPath[path]
+			if !ok {
+				e = &Entry{Path: path, Source: "tag", Depth: len(strings.Split(path, "/"))}
+				byPath[path] = e
+			}
+			e.Tags = append([]string(nil), tags...)
+		}
+	}
+	w := i.weights
+	i.mux.Unlock()
+	entries := make([]Entry, 0, len(byPath))
+	for _, e := range byPath {
+		e.Score = candidate{count: e.Count, visits: e.Visits, depth: e.Depth, project: e.Project}.score(w)
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Path < entries[b].Path
+	})
+	return entries
+}
+
+// Forget removes path from the index along with any history rank,
+// visits or tags it has. It returns the number of entries removed
+func (i *Index) Forget(path string) int {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	path = filepath.Clean(path)
+	base := filepath.Base(path)
+	d, ok := i.dirs[base]
+	if !ok {
+		return 0
+	}
+	removed := 0
+	if _, ok := d.tracker[path]; ok {
+		delete(d.tracker, path)
+		d.pathCandidates = removeCandidate(d.pathCandidates, path)
+		removed = 1
+	}
+	before := len(d.histCandidates)
+	d.histCandidates = removeCandidate(d.histCandidates, path)
+	if len(d.histCandidates) != before {
+		removed = 1
+	}
+	if _, ok := d.tags[path]; ok {
+		delete(d.tags, path)
+		removed = 1
+	}
+	if d.empty() {
+		delete(i.dirs, base)
+	}
+	if removed > 0 {
+		log.Debugln("Forgot", path)
+	}
+	return removed
+}
+
+// ForgetMatching removes every path which matches pattern, as understood
+// by filepath.Match, along with any history rank, visits or tags it has.
+// It returns the number of paths removed
+func (i *Index) ForgetMatching(pattern string) (int, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return 0, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	removed := i.forgetWhere(func(path string) bool {
+		ok, _ := filepath.Match(pattern, path)
+		return ok
+	})
+	log.Debugf("Forgot %d paths matching %s\n", removed, pattern)
+	return removed, nil
+}
+
+// forgetWhere removes every path for which match returns true, whether it
+// was walked, has a history rank or visits or was tagged, and returns the number of
+// paths removed. The caller must hold the lock
+func (i *Index) forgetWhere(match func(path string) bool) int {
+	removed := make(map[string]struct{})
+	for base, d := range i.dirs {
+		var paths, hist []candidate
+		for _, c := range d.pathCandidates {
+			if !match(c.path) {
+				paths = append(paths, c)
+				continue
+			}
+			delete(d.tracker, c.path)
+			removed[c.path] = struct{}{}
+		}
+		for _, c := range d.histCandidates {
+			if !match(c.path) {
+				hist = append(hist, c)
+				continue
+			}
+			removed[c.path] = struct{}{}
+		}
+		for path := range d.tags {
+			if match(path) {
+				delete(d.tags, path)
+				removed[path] = struct{}{}
+			}
+		}
+		d.pathCandidates, d.histCandidates = paths, hist
+		if d.empty() {
+			delete(i.dirs, base)
+		}
+	}
+	return len(removed)
+}
+
+// removeCandidate returns list without any candidate for path
+func removeCandidate(list []candidate, path string) []candidate {
+	kept := list[:0]
+	for _, c := range list {
+		if c.path != path {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+type snapshotCandidate struct {
+	Path      string `json:"path"`
+	Count     int    `json:"count,omitempty"`
+	Visits    int    `json:"visits,omitempty"`
+	LastVisit int64  `json:"last_visit,omitempty"`
+}
+
+type snapshotDir struct {
+	Name    string              `json:"name"`
+	History []snapshotCandidate `json:"history,omitempty"`
+	Paths   []string            `json:"paths,omitempty"`
+	Tags    map[string][]string `json:"tags,omitempty"`
+	// Projects lists the walked paths which are project roots
+	Projects []string `json:"projects,omitempty"`
+}
+
+type snapshot struct {
+	Version     int               `json:"version"`
+	Directories []snapshotDir     `json:"directories"`
+	Marks       map[string]string `json:"marks,omitempty"`
+}
+
+// Snapshot writes the contents of the index to w as JSON
+func (i *Index) Snapshot(w io.Writer) error {
+	i.mux.Lock()
+	snap := snapshot{Version: snapshotVersion, Marks: make(map[string]string)}
+	for name, path := range i.marks {
+		snap.Marks[name] = path
+	}
+	for name, d := range i.dirs {
+		sd := snapshotDir{Name: name}
+		for _, h := range d.histCandidates {
+			sc := snapshotCandidate{Path: h.path, Count: h.count, Visits: h.visits}
+			if !h.lastVisit.IsZero() {
+				sc.LastVisit = h.lastVisit.Unix()
+			}
+			sd.History = append(sd.History, sc)
+		}
+		for _, p := range d.pathCandidates {
+			sd.Paths = append(sd.Paths, p.path)
+			if p.project {
+				sd.Projects = append(sd.Projects, p.path)
+			}
+		}
+		if len(d.tags) > 0 {
+			sd.Tags = make(map[string][]string)
+			for path, tags := range d.tags {
+				sd.Tags[path] = append([]string(nil), tags...)
+			}
+		}
+		snap.Directories = append(snap.Directories, sd)
+	}
+	i.mux.Unlock()
+	sort.Slice(snap.Directories, func(a, b int) bool {
+		return snap.Directories[a].Name < snap.Directories[b].Name
+	})
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// SnapshotFile atomically replaces the file at path with a snapshot of the
+// index
+func (i *Index) SnapshotFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := i.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RestoreFile returns an Index populated from the snapshot file at path
+func RestoreFile(path string, opts ...Opt) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Restore(f, opts...)
+}
+
+// Restore returns an Index populated from a snapshot written by Snapshot.
+// Roots are not part of the snapshot and must be added again
+func Restore(r io.Reader, opts ...Opt) (*Index, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %v", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	i := New(opts...)
+	for _, sd := range snap.Directories {
+		for _, p := range sd.Paths {
+			i.addPath(p)
+		}
+		for _, p := range sd.Projects {
+			i.setProject(p, true)
+		}
+		d, ok := i.dirs[sd.Name]
+		if !ok {
+			d = &directory{path: sd.Name, tracker: make(map[string]struct{})}
+			i.dirs[sd.Name] = d
+		}
+		for _, h := range sd.History {
+			var visited time.Time
+			if h.LastVisit != 0 {
+				visited = time.Unix(h.LastVisit, 0)
+			}
+			d.addHistCandidate(h.Path, h.Count, h.Visits, visited)
+		}
+		for path, tags := range sd.Tags {
+			if d.tags == nil {
+				d.tags = make(map[string][]string)
+			}
+			d.tags[path] = sortedTags(tags)
+		}
+	}
+	for name, path := range snap.Marks {
+		i.marks[name] = path
+	}
+	return i, nil
+}
@@ -0,0 +1,1019 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalkLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxDirs       int
+		canceled      bool
+		want, notWant string
+		truncated     bool
+	}{
+		{
+			name:      "NoLimits",
+			want:      "last",
+			truncated: false,
+		},
+		{
+			name:      "MaxDepth",
+			maxDepth:  2,
+			want:      "next",
+			notWant:   "last",
+			truncated: true,
+		},
+		{
+			name:      "MaxDirs",
+			maxDirs:   1,
+			want:      "testdata",
+			notWant:   "top",
+			truncated: true,
+		},
+		{
+			name:      "Canceled",
+			canceled:  true,
+			notWant:   "testdata",
+			truncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.canceled {
+				cancel()
+			}
+			defer cancel()
+			idx := New()
+			stats, err := idx.AddRootContext(ctx, "../testdata", WalkOptions{MaxDepth: tt.maxDepth, MaxDirs: tt.maxDirs})
+			if err != nil {
+				t.Fatalf("Unexpected error walking: %v\n", err)
+			}
+			if tt.want != "" && !idx.Has(tt.want) {
+				t.Errorf("Expected %s to be indexed", tt.want)
+			}
+			if tt.notWant != "" && idx.Has(tt.notWant) {
+				t.Errorf("Expected %s not to be indexed", tt.notWant)
+			}
+			if (stats.Truncated != "") != tt.truncated {
+				t.Errorf("Expected truncated to be %t but got reason: '%s'", tt.truncated, stats.Truncated)
+			}
+		})
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "real", "proj"), 0755); err != nil {
+		t.Fatalf("Unable to create test directories: %v\n", err)
+	}
+	// A second route to real and a cycle back to the root
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Unable to create symlink: %v\n", err)
+	}
+	if err := os.Symlink("..", filepath.Join(root, "real", "loop")); err != nil {
+		t.Fatalf("Unable to create symlink: %v\n", err)
+	}
+	// A directory outside the root which only symlinks lead to
+	outside, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(outside)
+	if err := os.Mkdir(filepath.Join(outside, "lib"), 0755); err != nil {
+		t.Fatalf("Unable to create test directories: %v\n", err)
+	}
+	for _, name := range []string{"zz", "aa"} {
+		if err := os.Symlink(outside, filepath.Join(root, name)); err != nil {
+			t.Fatalf("Unable to create symlink: %v\n", err)
+		}
+	}
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatalf("Unable to resolve %s: %v\n", root, err)
+	}
+	resolvedOutside, err := filepath.EvalSymlinks(outside)
+	if err != nil {
+		t.Fatalf("Unable to resolve %s: %v\n", outside, err)
+	}
+	tests := []struct {
+		name      string
+		canonical bool
+		want      string
+		wantLib   string
+	}{
+		{
+			name:      "AsVisited",
+			canonical: false,
+			want:      filepath.Join(root, "real", "proj"),
+			wantLib:   filepath.Join(root, "aa", "lib"),
+		},
+		{
+			name:      "Canonical",
+			canonical: true,
+			want:      filepath.Join(resolved, "real", "proj"),
+			wantLib:   filepath.Join(resolvedOutside, "lib"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := New()
+			_, err := idx.AddRoot(root, WalkOptions{FollowSymlinks: true, CanonicalPaths: tt.canonical})
+			if err != nil {
+				t.Fatalf("Unexpected error walking: %v\n", err)
+			}
+			vals := idx.Query("proj")
+			if len(vals) != 1 {
+				t.Fatalf("Expected 1 candidate for proj but got %d: %v", len(vals), vals)
+			}
+			if vals[0].Path != tt.want {
+				t.Fatalf("Wanted '%s', got: '%s'", tt.want, vals[0].Path)
+			}
+			if vals := idx.Query("lib"); len(vals) != 1 || vals[0].Path != tt.wantLib {
+				t.Fatalf("Wanted only '%s' for lib, got: %v", tt.wantLib, vals)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	idx := New()
+	if _, err := idx.AddRoot("../testdata", WalkOptions{Skip: []string{"ignore"}}); err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	idx.AddHistory("/this/home/testdata/foo", 3)
+	idx.Visit("/somewhere/else")
+	if err := idx.Mark("prod", "/srv/deploy/production"); err != nil {
+		t.Fatalf("Unexpected error marking: %v\n", err)
+	}
+	var buf bytes.Buffer
+	if err := idx.Snapshot(&buf); err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v\n", err)
+	}
+	restored, err := Restore(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error restoring snapshot: %v\n", err)
+	}
+	for _, name := range []string{"last", "foo", "nex", "ignore", "else", "prod"} {
+		want := fmt.Sprint(idx.Query(name))
+		got := fmt.Sprint(restored.Query(name))
+		if got != want {
+			t.Errorf("Query of %s after restore: wanted '%s', got '%s'", name, want, got)
+		}
+	}
+}
+
+func TestAddRootPrunes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"a/kept", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := New()
+	for _, path := range []string{"a/kept", "a/gone", "b/other", "elsewhere"} {
+		old.AddPath(filepath.Join(dir, path))
+	}
+	old.AddHistory(filepath.Join(dir, "elsewhere"), 1)
+	var b bytes.Buffer
+	if err := old.Snapshot(&b); err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v", err)
+	}
+	idx, err := Restore(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error restoring snapshot: %v", err)
+	}
+	if _, err := idx.AddRoot(filepath.Join(dir, "b"), WalkOptions{}); err != nil {
+		t.Fatalf("Unexpected error walking: %v", err)
+	}
+	if _, err := idx.AddRoot(filepath.Join(dir, "a"), WalkOptions{MaxDirs: 1}); err != nil {
+		t.Fatalf("Unexpected error walking: %v", err)
+	}
+	if !idx.Has("gone") {
+		t.Errorf("Expected a truncated walk not to remove anything")
+	}
+	if _, err := idx.AddRoot(dir, WalkOptions{}); err != nil {
+		t.Fatalf("Unexpected error walking: %v", err)
+	}
+	var walked []string
+	for _, e := range idx.Entries() {
+		if e.Source == "walk" {
+			walked = append(walked, strings.TrimPrefix(e.Path, dir))
+		}
+	}
+	// a/gone is left to the truncated walk of its own root
+	want := "[ /a /a/gone /a/kept /b]"
+	if fmt.Sprint(walked) != want {
+		t.Errorf("Expected the walked directories %s but got %v", want, walked)
+	}
+	if got := idx.Query("elsewhere"); len(got) != 1 {
+		t.Errorf("Expected the history of a removed directory to be kept but got %v", got)
+	}
+}
+
+func TestHistoryCounts(t *testing.T) {
+	idx := New()
+	idx.AddPath("/src/api")
+	for _, history := range []string{"cd /src/api\ncd /src/api\n", "cd /src/api\ncd /src/api\ncd /src/api\n"} {
+		if err := idx.IngestHistory(strings.NewReader(history)); err != nil {
+			t.Fatalf("Unexpected error reading history: %v\n", err)
+		}
+	}
+	idx.AddHistory("/src/api", 4)
+	for _, e := range idx.Entries() {
+		if e.Path == "/src/api" && e.Count != 9 {
+			t.Errorf("Expected every history line to count but got %d", e.Count)
+		}
+	}
+}
+
+func TestRootErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"first", "second"} {
+		if err := os.Mkdir(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := New()
+	if _, err := idx.AddRoot(filepath.Join(dir, "missing"), WalkOptions{}); err == nil {
+		t.Errorf("Expected an error walking a missing root")
+	}
+	for _, path := range []string{"first", "second"} {
+		if _, err := idx.AddRoot(filepath.Join(dir, path), WalkOptions{}); err != nil {
+			t.Fatalf("Unexpected error walking %s: %v", path, err)
+		}
+	}
+	if got := idx.Roots(); len(got) != 2 {
+		t.Errorf("Expected only the roots which were walked but got %v", got)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "second", "new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := idx.Refresh()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "first")) {
+		t.Errorf("Expected an error naming the missing root but got %v", err)
+	}
+	if len(stats) != 1 || !idx.Has("new") {
+		t.Errorf("Expected the remaining root to be walked but got %v", stats)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	idx := New(WithHome("/this/home"))
+	if _, err := idx.AddRoot("../testdata", WalkOptions{Skip: []string{"ignore"}}); err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	f, err := os.Open("../testdata/histfile")
+	if err != nil {
+		t.Fatalf("Unable to open history: %v\n", err)
+	}
+	defer f.Close()
+	if err := idx.IngestHistory(f); err != nil {
+		t.Fatalf("Unexpected error reading history: %v\n", err)
+	}
+	idx.Visit("/elsewhere/next")
+	idx.Visit("/elsewhere/next")
+	for name, path := range map[string]string{"prod": "/srv/deploy/production", "top": "/marked/top"} {
+		if err := idx.Mark(name, path); err != nil {
+			t.Fatalf("Unexpected error marking %s: %v\n", path, err)
+		}
+	}
+	tests := []struct {
+		name, search string
+		want         []Result
+	}{
+		{
+			name:   "Exact",
+			search: "last",
+			want:   []Result{{Path: "../testdata/top/next/last", Match: Exact}},
+		},
+		{
+			name:   "None",
+			search: "ignore",
+			want:   nil,
+		},
+		{
+			name:   "Partial",
+			search: "nex",
+			want:   []Result{{Path: "next", Match: Partial}},
+		},
+		{
+			name:   "History",
+			search: "foo",
+			want: []Result{
+				{Path: "/this/home/testdata/foo", Match: Exact},
+				{Path: "../testdata/foo", Match: Exact},
+			},
+		},
+		{
+			name:   "Visited",
+			search: "next",
+			want: []Result{
+				{Path: "/elsewhere/next", Match: Exact},
+				{Path: "../testdata/top/next", Match: Exact},
+			},
+		},
+		{
+			name:   "Bookmark",
+			search: "prod",
+			want:   []Result{{Path: "/srv/deploy/production", Match: Bookmarked}},
+		},
+		{
+			name:   "BookmarkFirst",
+			search: "top",
+			want: []Result{
+				{Path: "/marked/top", Match: Bookmarked},
+				{Path: "../testdata/top", Match: Exact},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := idx.Query(tt.search)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("Wanted %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights Weights
+		want    string
+	}{
+		{
+			name:    "Defaults",
+			weights: DefaultWeights,
+			want:    "/a/b/c/d/e/next",
+		},
+		{
+			name:    "DepthOutweighsVisits",
+			weights: Weights{History: 100, Visit: 1, Depth: 10},
+			want:    "/next",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := New(WithWeights(tt.weights))
+			idx.AddPath("/next")
+			idx.Visit("/a/b/c/d/e/next")
+			got := idx.Query("next")
+			if len(got) != 2 || got[0].Path != tt.want {
+				t.Fatalf("Wanted %s first, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQueryFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"work/api/.git", "work/api/cmd", "personal/blog/src", "work/api/src"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		cwd     string
+		weights Weights
+		want    string
+	}{
+		{
+			name:    "NoCwd",
+			weights: DefaultWeights,
+			want:    filepath.Join(dir, "personal/blog/src"),
+		},
+		{
+			name:    "SameRepository",
+			cwd:     filepath.Join(dir, "work/api/cmd"),
+			weights: DefaultWeights,
+			want:    filepath.Join(dir, "work/api/src"),
+		},
+		{
+			name:    "Disabled",
+			cwd:     filepath.Join(dir, "work/api/cmd"),
+			weights: Weights{History: 100, Visit: 100, Depth: 1},
+			want:    filepath.Join(dir, "personal/blog/src"),
+		},
+		{
+			name:    "CommonPrefix",
+			cwd:     filepath.Join(dir, "work"),
+			weights: Weights{History: 100, Visit: 1, Depth: 1, Proximity: 10},
+			want:    filepath.Join(dir, "work/api/src"),
+		},
+		{
+			name:    "Relative",
+			cwd:     "work/api",
+			weights: Weights{History: 100, Visit: 1, Depth: 1, Proximity: 10, Repo: 100},
+			want:    filepath.Join(dir, "personal/blog/src"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := New(WithWeights(tt.weights))
+			idx.AddPath(filepath.Join(dir, "work/api/src"))
+			idx.Visit(filepath.Join(dir, "personal/blog/src"))
+			got := idx.QueryFrom("src", tt.cwd)
+			if len(got) != 2 || got[0].Path != tt.want {
+				t.Fatalf("Wanted %s first, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	idx := New()
+	stats, err := idx.AddRoot("../testdata", WalkOptions{Ignore: []string{"ign*", "../testdata/top/next"}})
+	if err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	for _, rule := range []string{"ign*", "../testdata/top/next"} {
+		if stats.SkipHits[rule] != 1 {
+			t.Errorf("Expected one skip for %s but got %d", rule, stats.SkipHits[rule])
+		}
+	}
+	for _, name := range []string{"ignore", "next", "last"} {
+		if idx.Has(name) {
+			t.Errorf("Expected %s to be ignored", name)
+		}
+	}
+	if !idx.Has("top") {
+		t.Errorf("Expected top to be indexed")
+	}
+	if _, err := New().AddRoot("../testdata", WalkOptions{Ignore: []string{"["}}); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+func TestStats(t *testing.T) {
+	idx := New(WithHome("/home/user"))
+	idx.AddPath("/a/proj")
+	idx.AddPath("/b/proj")
+	idx.AddPath("/a/other")
+	history := ": 1:0;cd /a/proj\n: 2:0;cd ~/proj\n: 3:0;cd relative/dir\n: 4:0;ls\n"
+	if err := idx.IngestHistory(strings.NewReader(history)); err != nil {
+		t.Fatalf("Unexpected error ingesting history: %v\n", err)
+	}
+	want := Stats{Names: 2, Paths: 4, History: 2, HistoryLines: 4, HistoryFailures: 1}
+	if got := idx.Stats(); got != want {
+		t.Errorf("Expected %+v but got %+v", want, got)
+	}
+}
+
+func TestErrorSkips(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("unreadable directories can still be read by root")
+	}
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	locked := filepath.Join(dir, "locked")
+	if err := os.MkdirAll(filepath.Join(locked, "inner"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0700)
+	stats, err := New().AddRoot(dir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	if stats.ErrorSkips != 1 {
+		t.Errorf("Expected one unreadable directory but got %d", stats.ErrorSkips)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mkdirs := func(paths ...string) {
+		for _, p := range paths {
+			if err := os.MkdirAll(filepath.Join(dir, p), 0700); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	mkdirs("a/old", "b/keep")
+	idx := New()
+	if _, err := idx.AddRoot(dir, WalkOptions{}); err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	idx.AddHistory(filepath.Join(dir, "a/old"), 1)
+	mkdirs("a/new/inner", "b/other")
+	if err := os.RemoveAll(filepath.Join(dir, "a/old")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		path           string
+		added, removed int
+		has, missing   []string
+		wantErr        error
+	}{
+		{
+			name:    "Subtree",
+			path:    filepath.Join(dir, "a"),
+			added:   2,
+			removed: 1,
+			has:     []string{"new", "inner"},
+			missing: []string{"other"},
+		},
+		{
+			name:  "AllRoots",
+			added: 1,
+			has:   []string{"other"},
+		},
+		{
+			name:    "NotBelowRoot",
+			path:    "/elsewhere",
+			wantErr: ErrNoRoot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := idx.Reindex(context.Background(), tt.path)
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v but got %v", tt.wantErr, err)
+			}
+			if stats.Added != tt.added || stats.Removed != tt.removed {
+				t.Errorf("Expected %d added and %d removed but got %+v", tt.added, tt.removed, stats)
+			}
+			for _, name := range tt.has {
+				if !idx.Has(name) {
+					t.Errorf("Expected %s to be indexed", name)
+				}
+			}
+			for _, name := range tt.missing {
+				if idx.Has(name) {
+					t.Errorf("Expected %s not to be indexed yet", name)
+				}
+			}
+		})
+	}
+	if got := idx.Query("old"); len(got) != 1 {
+		t.Errorf("Expected the history of a removed directory to be kept but got %v", got)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, p := range []string{"a", "b/c", "skipped", "deep/one/two"} {
+		if err := os.MkdirAll(filepath.Join(dir, p), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := New()
+	if _, err := idx.AddRoot(dir, WalkOptions{Skip: []string{"skipped"}, Markers: DefaultMarkers}); err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	for _, p := range []string{"a/new/target", "b/c/near", "skipped/target2", "deep/one/edge", "deep/one/two/beyond", "a/app"} {
+		path := filepath.Join(dir, p)
+		if err := os.MkdirAll(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a/app/go.mod"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Timestamps are coarser than the clock, so make sure that a looks as
+	// though it changed after the walk. b/c has changed but looks as though
+	// it hasn't, so near can only be found by searching around the cwd
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"b/c", "deep/one", "deep/one/two"} {
+		if err := os.Chtimes(filepath.Join(dir, p), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name, search, cwd string
+		added             int
+	}{
+		{name: "ChangedSinceWalk", search: "target", added: 1},
+		{name: "NotNearCwd", search: "near", added: 0},
+		{name: "NearCwd", search: "near", cwd: filepath.Join(dir, "b"), added: 1},
+		{name: "Skipped", search: "target2", cwd: dir, added: 0},
+		{name: "Anchored", search: "^app", added: 1},
+		{name: "AtDepth", search: "edge", cwd: filepath.Join(dir, "deep"), added: 1},
+		{name: "BeyondDepth", search: "beyond", cwd: filepath.Join(dir, "deep"), added: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idx.Probe(context.Background(), tt.search, tt.cwd); got != tt.added {
+				t.Errorf("Expected %d directories added but got %d", tt.added, got)
+			}
+		})
+	}
+	if got := idx.Query("target"); len(got) != 1 || got[0].Path != filepath.Join(dir, "a/new/target") {
+		t.Errorf("Expected the probed directory to be indexed but got %v", got)
+	}
+	if got := idx.Query("^app"); len(got) != 1 || got[0].Path != filepath.Join(dir, "a/app") {
+		t.Errorf("Expected the probed project root to match an anchored query but got %v", got)
+	}
+}
+
+func TestForgetMatching(t *testing.T) {
+	idx := New()
+	for _, path := range []string{"/tmp/scratch1", "/tmp/scratch2", "/home/scratch3"} {
+		idx.AddPath(path)
+		idx.Visit(path)
+	}
+	removed, err := idx.ForgetMatching("/tmp/scratch*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 paths removed but got %d", removed)
+	}
+	for _, name := range []string{"scratch1", "scratch2"} {
+		if idx.Has(name) {
+			t.Errorf("Expected %s to be forgotten", name)
+		}
+	}
+	if !idx.Has("scratch3") {
+		t.Errorf("Expected scratch3 to be kept")
+	}
+	if _, err := idx.ForgetMatching("["); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	idx := New(WithHome("/home/user"))
+	idx.AddPath("/home/user/top")
+	idx.Visit("/home/user/top")
+	removed, err := idx.SetBlocklist([]string{"/home/user/top", "../testdata/*/next"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if removed != 1 || idx.Has("top") {
+		t.Errorf("Expected the blocked path to be removed but %d were", removed)
+	}
+	idx.Visit("/home/user/top/below")
+	idx.AddPath("/home/user/top")
+	if err := idx.IngestHistory(strings.NewReader("cd ~/top\n")); err != nil {
+		t.Fatalf("Unexpected error ingesting history: %v\n", err)
+	}
+	if idx.Has("top") || idx.Has("below") {
+		t.Errorf("Expected blocked paths not to be indexed")
+	}
+	stats, err := idx.AddRoot("../testdata", WalkOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error walking: %v\n", err)
+	}
+	if idx.Has("next") || idx.Has("last") {
+		t.Errorf("Expected the walk to skip blocked directories")
+	}
+	if stats.SkipHits["../testdata/*/next"] != 1 {
+		t.Errorf("Expected one skip by the blocklist but got %v", stats.SkipHits)
+	}
+	if _, err := idx.SetBlocklist([]string{"["}); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+// zoxideDB encodes dirs as a version 3 zoxide database
+func zoxideDB(dirs []Imported) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(3))
+	binary.Write(&b, binary.LittleEndian, uint64(len(dirs)))
+	for _, d := range dirs {
+		binary.Write(&b, binary.LittleEndian, uint64(len(d.Path)))
+		b.WriteString(d.Path)
+		binary.Write(&b, binary.LittleEndian, d.Rank)
+		binary.Write(&b, binary.LittleEndian, uint64(d.LastVisit.Unix()))
+	}
+	return b.Bytes()
+}
+
+func TestParseDatabase(t *testing.T) {
+	visited := time.Unix(1600000000, 0)
+	want := []Imported{
+		{Path: "/home/user/src", Rank: 12.5, LastVisit: visited},
+		{Path: "/home/user/a|b", Rank: 2, LastVisit: visited},
+	}
+	tests := []struct {
+		name, format string
+		data         []byte
+		want         []Imported
+		wantErr      bool
+	}{
+		{
+			name:   "Z",
+			format: "z",
+			data:   []byte("/home/user/src|12.5|1600000000\n/home/user/a|b|2|1600000000\n"),
+			want:   want,
+		},
+		{
+			name:   "Fasd",
+			format: "fasd",
+			data:   []byte("/home/user/src|12.5|1600000000\n/home/user/a|b|2|1600000000\n"),
+			want:   want,
+		},
+		{
+			name:   "Autojump",
+			format: "autojump",
+			data:   []byte("12.5\t/home/user/src\n2.0\t/home/user/a|b\n"),
+			want:   []Imported{{Path: "/home/user/src", Rank: 12.5}, {Path: "/home/user/a|b", Rank: 2}},
+		},
+		{
+			name:   "Zoxide",
+			format: "zoxide",
+			data:   zoxideDB(want),
+			want:   want,
+		},
+		{
+			name:    "ZoxideTruncated",
+			format:  "zoxide",
+			data:    zoxideDB(want)[:30],
+			wantErr: true,
+		},
+		{
+			name:    "ZoxideVersion",
+			format:  "zoxide",
+			data:    []byte{4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "ZBadRank",
+			format:  "z",
+			data:    []byte("/home/user/src|x|1600000000\n"),
+			wantErr: true,
+		},
+		{
+			name:    "UnknownFormat",
+			format:  "cdargs",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDatabase(tt.format, bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %t but got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	idx := New()
+	idx.AddPath("/walked/proj")
+	idx.AddHistory("/walked/proj", 40)
+	if _, err := idx.SetBlocklist([]string{"/blocked"}); err != nil {
+		t.Fatal(err)
+	}
+	imported := idx.Import([]Imported{
+		{Path: "/imported/top", Rank: 200, LastVisit: time.Unix(1700000000, 0)},
+		{Path: "/imported/low", Rank: 1},
+		{Path: "relative", Rank: 5},
+		{Path: "/blocked/dir", Rank: 5},
+	})
+	if imported != 2 {
+		t.Errorf("Expected 2 paths imported but got %d", imported)
+	}
+	counts := make(map[string]int)
+	for _, e := range idx.Entries() {
+		counts[e.Path] = e.Count
+		if strings.HasPrefix(e.Path, "/imported/") && e.Source != "history" {
+			t.Errorf("Expected %s to come from the history but got %s", e.Path, e.Source)
+		}
+	}
+	if counts["/imported/top"] != 40 || counts["/imported/low"] != 1 {
+		t.Errorf("Expected ranks scaled to the existing history but got %v", counts)
+	}
+}
+
+func TestMarks(t *testing.T) {
+	idx := New()
+	for _, name := range []string{"", "a/b", "a:b", "@tag"} {
+		if err := idx.Mark(name, "/srv"); err == nil {
+			t.Errorf("Expected an error for the bookmark name '%s'", name)
+		}
+	}
+	if err := idx.Mark("srv", "relative"); err == nil {
+		t.Errorf("Expected an error for a relative path")
+	}
+	idx.Mark("web", "/srv/web")
+	idx.Mark("api", "/srv/api/")
+	want := []Bookmark{{Name: "api", Path: "/srv/api"}, {Name: "web", Path: "/srv/web"}}
+	if got := idx.Marks(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v but got %v", want, got)
+	}
+	if !idx.Unmark("web") || idx.Unmark("web") {
+		t.Errorf("Expected web to be unmarked exactly once")
+	}
+	if got := idx.Query("web"); len(got) != 0 {
+		t.Errorf("Expected no match after unmarking but got %v", got)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		tags  []string
+	}{
+		{query: "api", name: "api"},
+		{query: "my dir", name: "my dir"},
+		{query: "@backend", tags: []string{"backend"}},
+		{query: "api @backend @go", name: "api", tags: []string{"backend", "go"}},
+		{query: "@ api", name: "@ api"},
+	}
+	for _, tt := range tests {
+		name, tags := ParseQuery(tt.query)
+		if name != tt.name || fmt.Sprint(tags) != fmt.Sprint(tt.tags) {
+			t.Errorf("%q: expected %q %v but got %q %v", tt.query, tt.name, tt.tags, name, tags)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	idx := New()
+	idx.AddPath("/src/api")
+	idx.AddPath("/work/api")
+	idx.AddPath("/src/web")
+	idx.AddPath("/node/@types")
+	idx.AddHistory("/work/api", 2)
+	for _, tag := range []string{"", "a b", "a,b", "@"} {
+		if err := idx.Tag("/src/api", tag); err == nil {
+			t.Errorf("Expected an error for the tag '%s'", tag)
+		}
+	}
+	if err := idx.Tag("relative", "x"); err == nil {
+		t.Errorf("Expected an error for a relative path")
+	}
+	for path, tags := range map[string][]string{
+		"/src/api":     {"backend", "go"},
+		"/work/api":    {"@backend"},
+		"/src/web":     {"frontend"},
+		"/srv/api-gw":  {"backend", "backend"},
+		"/src/api/cmd": {"go"},
+	} {
+		if err := idx.Tag(path, tags...); err != nil {
+			t.Fatalf("Unexpected error tagging %s: %v", path, err)
+		}
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "@backend", want: []string{"/work/api", "/src/api", "/srv/api-gw"}},
+		{query: "api @backend", want: []string{"/work/api", "/src/api", "/srv/api-gw"}},
+		{query: "@go api", want: []string{"/src/api"}},
+		{query: "@backend @go", want: []string{"/src/api"}},
+		{query: "web @backend", want: nil},
+		{query: "@types", want: []string{"/node/@types"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range idx.Query(tt.query) {
+			got = append(got, r.Path)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: expected %v but got %v", tt.query, tt.want, got)
+		}
+	}
+	if got := idx.Tags("/srv/api-gw"); fmt.Sprint(got) != "[backend]" {
+		t.Errorf("Expected a single backend tag but got %v", got)
+	}
+	var b bytes.Buffer
+	if err := idx.Snapshot(&b); err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v", err)
+	}
+	restored, err := Restore(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error restoring snapshot: %v", err)
+	}
+	if got := restored.Tags("/src/api"); fmt.Sprint(got) != "[backend go]" {
+		t.Errorf("Expected the restored tags [backend go] but got %v", got)
+	}
+	if n := idx.Untag("/src/api", "go", "missing"); n != 1 {
+		t.Errorf("Expected to remove 1 tag but removed %d", n)
+	}
+	if n := idx.Untag("/srv/api-gw"); n != 1 {
+		t.Errorf("Expected to remove every tag but removed %d", n)
+	}
+	if idx.Has("api-gw") {
+		t.Errorf("Expected api-gw to be dropped once it had no tags")
+	}
+	if n := idx.Forget("/work/api"); n != 1 || len(idx.Tags("/work/api")) != 0 {
+		t.Errorf("Expected forgetting /work/api to remove its tags")
+	}
+}
+
+func TestProjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"work/api/.git", "work/api/cmd", "old/api", "lib"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib/go.mod"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx := New()
+	if _, err := idx.AddRoot(dir, WalkOptions{Skip: []string{".git"}, Markers: DefaultMarkers}); err != nil {
+		t.Fatalf("Unexpected error walking %s: %v", dir, err)
+	}
+	idx.AddHistory(filepath.Join(dir, "old/api"), 1)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "^api", want: []string{filepath.Join(dir, "work/api")}},
+		{query: "^li", want: []string{filepath.Join(dir, "lib")}},
+		{query: "^cmd", want: nil},
+		{query: "api", want: []string{filepath.Join(dir, "old/api"), filepath.Join(dir, "work/api")}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range idx.Query(tt.query) {
+			got = append(got, r.Path)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: expected %v but got %v", tt.query, tt.want, got)
+		}
+	}
+	idx.SetWeights(Weights{History: 100, Visit: 100, Depth: 1, Project: 200})
+	if got := idx.Query("api"); len(got) != 2 || got[0].Path != filepath.Join(dir, "work/api") {
+		t.Errorf("Expected the project root to rank first, got: %v", got)
+	}
+	var b bytes.Buffer
+	if err := idx.Snapshot(&b); err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v", err)
+	}
+	restored, err := Restore(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error restoring snapshot: %v", err)
+	}
+	if got := restored.Query("^api"); len(got) != 1 || got[0].Path != filepath.Join(dir, "work/api") {
+		t.Errorf("Expected the restored index to keep the project root, got: %v", got)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "work/api/.git")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Reindex(context.Background(), filepath.Join(dir, "work")); err != nil {
+		t.Fatalf("Unexpected error reindexing: %v", err)
+	}
+	for _, e := range idx.Entries() {
+		if e.Project != (e.Path == filepath.Join(dir, "lib")) {
+			t.Errorf("Unexpected project flag %t on %s after reindexing", e.Project, e.Path)
+		}
+	}
+}
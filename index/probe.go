@@ -0,0 +1,184 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/karrick/godirwalk"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// probeDepth is how many levels below each directory Probe searches
+	probeDepth = 2
+	// probeStats caps how many walked directories Probe checks for changes
+	probeStats = 1000
+)
+
+// prober holds the state of a single Probe
+type prober struct {
+	ctx   context.Context
+	idx   *Index
+	name  string
+	roots []root
+	block []string
+	added int
+	// filters holds the filter of each root searched so far, or nil for
+	// a root which can't be walked
+	filters map[string]*filter
+	// searched records the depth each directory has been searched to
+	searched map[string]int
+}
+
+// Probe looks for directories whose basename is or contains name which the
+// index doesn't know about yet, such as ones created since the last walk.
+// It searches a few levels around cwd, then below every walked directory
+// which has been modified since its root was last walked, nearest to cwd
+// first, until ctx is done or probeStats directories have been checked.
+// Only directories below a root are searched, leaving out any that a walk
+// of the root would. Matching directories are added to the index and the
+// number added is returned
+func (i *Index) Probe(ctx context.Context, name, cwd string) int {
+	// name is a query, so any tags and a leading '^' aren't part of the
+	// directory name
+	name, _ = ParseQuery(name)
+	name, _ = parseProject(name)
+	if name == "" {
+		return 0
+	}
+	i.mux.Lock()
+	p := &prober{
+		ctx:      ctx,
+		idx:      i,
+		name:     name,
+		roots:    append([]root(nil), i.roots...),
+		block:    append([]string(nil), i.blocklist...),
+		filters:  make(map[string]*filter),
+		searched: make(map[string]int),
+	}
+	var walked []string
+	for _, d := range i.dirs {
+		for _, c := range d.pathCandidates {
+			walked = append(walked, c.path)
+		}
+	}
+	i.mux.Unlock()
+	if cwd != "" {
+		cwd = filepath.Clean(cwd)
+		p.search(cwd, probeDepth)
+		p.search(filepath.Dir(cwd), probeDepth-1)
+	}
+	// Directories closer to cwd are checked first, so that the ones which
+	// are checked before reaching probeStats don't depend on map order
+	sort.Slice(walked, func(a, b int) bool {
+		sa, sb := sharedElements(walked[a], cwd), sharedElements(walked[b], cwd)
+		if sa != sb {
+			return sa > sb
+		}
+		return walked[a] < walked[b]
+	})
+	stats := 0
+	for _, path := range walked {
+		if ctx.Err() != nil {
+			break
+		}
+		r, ok := p.rootOf(path)
+		if !ok || r.walked.IsZero() {
+			continue
+		}
+		if stats == probeStats {
+			log.Debugf("Stopped searching for %s after checking %d directories for changes\n", name, stats)
+			break
+		}
+		stats++
+		fi, err := os.Stat(path)
+		if err != nil || !fi.ModTime().After(r.walked) {
+			continue
+		}
+		log.Debugf("Searching %s for %s as it changed after the last walk\n", path, name)
+		p.search(path, probeDepth)
+	}
+	if ctx.Err() != nil {
+		log.Debugf("Stopped searching for %s: %v\n", name, ctx.Err())
+	}
+	return p.added
+}
+
+// rootOf returns the innermost root which dir is below
+func (p *prober) rootOf(dir string) (root, bool) {
+	var found root
+	var ok bool
+	for _, r := range p.roots {
+		if under(dir, filepath.Clean(r.path)) && len(r.path) > len(found.path) {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
+
+// search adds every matching directory up to depth levels below dir
+func (p *prober) search(dir string, depth int) {
+	if depth <= 0 || p.ctx.Err() != nil {
+		return
+	}
+	if searched, ok := p.searched[dir]; ok && searched >= depth {
+		return
+	}
+	p.searched[dir] = depth
+	r, ok := p.rootOf(dir)
+	if !ok {
+		return
+	}
+	f := p.filter(r)
+	if f == nil {
+		return
+	}
+	dirents, err := godirwalk.ReadDirents(dir, nil)
+	if err != nil {
+		return
+	}
+	for _, de := range dirents {
+		if !de.IsDir() {
+			continue
+		}
+		child := filepath.Join(dir, de.Name())
+		var id fileID
+		if r.opts.OneFileSystem {
+			if id, err = statID(child); err != nil {
+				continue
+			}
+		}
+		if reason, _ := f.excludes(child, id); reason != included {
+			continue
+		}
+		if strings.Contains(de.Name(), p.name) {
+			project := hasMarker(child, r.opts.Markers)
+			p.idx.mux.Lock()
+			if p.idx.addPath(child) {
+				log.Debugf("Found %s while searching for %s\n", child, p.name)
+				p.added++
+				p.idx.setProject(child, project)
+			}
+			p.idx.mux.Unlock()
+		}
+		p.search(child, depth-1)
+	}
+}
+
+// filter returns the filter a walk of r applies, or nil if r can't be
+// walked
+func (p *prober) filter(r root) *filter {
+	if f, ok := p.filters[r.path]; ok {
+		return f
+	}
+	f, err := newFilter(r.path, r.opts, p.block)
+	if err != nil {
+		log.Debugf("Not searching below %s: %v\n", r.path, err)
+		f = nil
+	}
+	p.filters[r.path] = f
+	return f
+}
@@ -0,0 +1,61 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMarkers are the files and directories which mark a project root
+// by default
+var DefaultMarkers = []string{".git", "go.mod", "package.json", "Cargo.toml", "pyproject.toml"}
+
+// parseProject reports whether name asks only for project roots, which is
+// written as '^name', and returns name without the '^'
+func parseProject(name string) (string, bool) {
+	if len(name) > 1 && strings.HasPrefix(name, "^") {
+		return name[1:], true
+	}
+	return name, false
+}
+
+// setProject records whether the walked directory path is a project root.
+// The caller must hold the lock
+func (i *Index) setProject(path string, project bool) {
+	d, ok := i.dirs[filepath.Base(path)]
+	if !ok {
+		return
+	}
+	for n, c := range d.pathCandidates {
+		if c.path == path && c.project != project {
+			d.pathCandidates[n].project = project
+			if project {
+				log.Debugln("Found a project root at", path)
+			}
+		}
+	}
+}
+
+// projects returns the set of walked paths of the directory which are
+// project roots
+func (d *directory) projects() map[string]bool {
+	projects := make(map[string]bool)
+	for _, c := range d.pathCandidates {
+		if c.project {
+			projects[c.path] = true
+		}
+	}
+	return projects
+}
+
+// hasMarker reports whether dir holds any of markers
+func hasMarker(dir string, markers []string) bool {
+	for _, m := range markers {
+		if _, err := os.Lstat(filepath.Join(dir, m)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vantage is the directory a query was made from. Exact matches near it
+// rank higher
+type vantage struct {
+	cwd string
+	// repo is the root of the repository containing cwd, if there is one
+	repo string
+}
+
+// newVantage returns the vantage of a query made from cwd. A relative or
+// empty cwd gives no boost to any path
+func newVantage(cwd string) vantage {
+	if !filepath.IsAbs(cwd) {
+		return vantage{}
+	}
+	cwd = filepath.Clean(cwd)
+	return vantage{cwd: cwd, repo: repoRoot(cwd)}
+}
+
+// repoRoot returns the closest directory at or above dir which holds a
+// .git entry, or "" if there isn't one
+func repoRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// sharedElements counts the leading path elements that path and dir have
+// in common
+func sharedElements(path, dir string) int {
+	a := strings.Split(strings.Trim(path, "/"), "/")
+	b := strings.Split(strings.Trim(dir, "/"), "/")
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] && a[n] != "" {
+		n++
+	}
+	return n
+}
+
+// boost returns the score path gains under w for being near the vantage
+func (v vantage) boost(w Weights, path string) float64 {
+	if v.cwd == "" {
+		return 0
+	}
+	b := w.Proximity * float64(sharedElements(path, v.cwd))
+	if v.repo != "" && under(path, v.repo) {
+		b += w.Repo
+	}
+	return b
+}
+
+// explain describes how c's score under w from the vantage was reached
+func (v vantage) explain(w Weights, c candidate) string {
+	s := fmt.Sprintf("%.1f from %d history, %d visits and depth %d", c.score(w)+v.boost(w, c.path), c.count, c.visits, c.depth)
+	if c.project {
+		s += ", as a project root"
+	}
+	if v.cwd == "" {
+		return s
+	}
+	s += fmt.Sprintf(", sharing %d elements with %s", sharedElements(c.path, v.cwd), v.cwd)
+	if v.repo != "" && under(c.path, v.repo) {
+		s += fmt.Sprintf(" in the repository at %s", v.repo)
+	}
+	return s
+}
@@ -0,0 +1,206 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ParseQuery splits a query into the name being searched for and the tags,
+// written as terms beginning with '@', which every match must carry. A
+// query without any tags is returned unchanged as the name
+func ParseQuery(query string) (string, []string) {
+	fields := strings.Fields(query)
+	var names, tags []string
+	for _, f := range fields {
+		if strings.HasPrefix(f, "@") {
+			if tag := strings.TrimPrefix(f, "@"); tag != "" {
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		names = append(names, f)
+	}
+	if len(tags) == 0 {
+		return query, nil
+	}
+	return strings.Join(names, " "), tags
+}
+
+// validTag returns tag without any leading '@', or an error if it can't be
+// written in a query
+func validTag(tag string) (string, error) {
+	tag = strings.TrimPrefix(tag, "@")
+	if tag == "" || strings.ContainsAny(tag, " \t\n@:;,") {
+		return "", fmt.Errorf("invalid tag '%s'", tag)
+	}
+	return tag, nil
+}
+
+// hasTags reports whether every one of want is in tags
+func hasTags(tags, want []string) bool {
+	for _, w := range want {
+		n := sort.SearchStrings(tags, w)
+		if n == len(tags) || tags[n] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// Tag labels path with each of tags so that it can be found with an
+// '@tag' query. path must be absolute and needn't have been walked
+func (i *Index) Tag(path string, tags ...string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("tagged path %s is not absolute", path)
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags given for %s", path)
+	}
+	clean := make([]string, len(tags))
+	for n, tag := range tags {
+		t, err := validTag(tag)
+		if err != nil {
+			return err
+		}
+		clean[n] = t
+	}
+	path = filepath.Clean(path)
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if pattern, ok := blockedBy(i.blocklist, path); ok {
+		return fmt.Errorf("%s is blocked by %s", path, pattern)
+	}
+	base := filepath.Base(path)
+	d, ok := i.dirs[base]
+	if !ok {
+		d = &directory{path: base, tracker: make(map[string]struct{})}
+		i.dirs[base] = d
+	}
+	if d.tags == nil {
+		d.tags = make(map[string][]string)
+	}
+	for _, t := range clean {
+		if !hasTags(d.tags[path], []string{t}) {
+			d.tags[path] = append(d.tags[path], t)
+			sort.Strings(d.tags[path])
+		}
+	}
+	log.Debugf("Tagged %s with %s\n", path, strings.Join(d.tags[path], ","))
+	return nil
+}
+
+// Untag removes each of tags from path, or every tag it has if tags is
+// empty. It returns the number of tags removed
+func (i *Index) Untag(path string, tags ...string) int {
+	path = filepath.Clean(path)
+	base := filepath.Base(path)
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	d, ok := i.dirs[base]
+	if !ok {
+		return 0
+	}
+	before := len(d.tags[path])
+	var kept []string
+	if len(tags) > 0 {
+		remove := sortedTags(tags)
+		for _, t := range d.tags[path] {
+			if !hasTags(remove, []string{t}) {
+				kept = append(kept, t)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		delete(d.tags, path)
+	} else {
+		d.tags[path] = kept
+	}
+	if d.empty() {
+		delete(i.dirs, base)
+	}
+	return before - len(kept)
+}
+
+// sortedTags returns a sorted copy of tags with any leading '@' removed
+func sortedTags(tags []string) []string {
+	sorted := make([]string, len(tags))
+	for n, t := range tags {
+		sorted[n] = strings.TrimPrefix(t, "@")
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Tags returns the tags on path
+func (i *Index) Tags(path string) []string {
+	path = filepath.Clean(path)
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if d, ok := i.dirs[filepath.Base(path)]; ok {
+		return append([]string(nil), d.tags[path]...)
+	}
+	return nil
+}
+
+// querySelected returns every path carrying all of tags, and which is a
+// project root if project is set, best first, as Exact results. If name
+// isn't empty only paths whose basename is name or, after those, contains
+// name are returned. Paths are ranked from the vantage v. The caller must
+// hold the lock
+func (i *Index) querySelected(name string, tags []string, project bool, v vantage) []Result {
+	type ranked struct {
+		path  string
+		exact bool
+		score float64
+	}
+	want := sortedTags(tags)
+	var found []ranked
+	for base, d := range i.dirs {
+		exact := base == name
+		if name != "" && !exact && !strings.Contains(base, name) {
+			continue
+		}
+		paths := make(map[string]struct{})
+		if len(want) > 0 {
+			for path, pathTags := range d.tags {
+				if hasTags(pathTags, want) {
+					paths[path] = struct{}{}
+				}
+			}
+		} else {
+			for _, list := range [][]candidate{d.histCandidates, d.pathCandidates} {
+				for _, c := range list {
+					paths[c.path] = struct{}{}
+				}
+			}
+		}
+		projects := d.projects()
+		for path := range paths {
+			if project && !projects[path] {
+				continue
+			}
+			c := d.candidate(path)
+			c.project = projects[path]
+			found = append(found, ranked{path: path, exact: exact, score: c.score(i.weights) + v.boost(i.weights, path)})
+		}
+	}
+	sort.Slice(found, func(a, b int) bool {
+		if found[a].exact != found[b].exact {
+			return found[a].exact
+		}
+		if found[a].score != found[b].score {
+			return found[a].score > found[b].score
+		}
+		return found[a].path < found[b].path
+	})
+	results := make([]Result, len(found))
+	for n, f := range found {
+		results[n] = Result{Path: f.path, Match: Exact}
+	}
+	log.Debugf("Found %d paths tagged %s matching '%s' (projects only: %t)\n", len(results), strings.Join(want, ","), name, project)
+	return results
+}
@@ -0,0 +1,361 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/karrick/godirwalk"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errMaxDirs     = errors.New("directory limit reached")
+	errWalkTimeout = errors.New("walk time budget exceeded")
+	errWalkCancel  = errors.New("walk canceled")
+)
+
+// WalkOptions controls how a root is walked
+type WalkOptions struct {
+	// Skip lists directory basenames or full paths which are not indexed
+	// or descended into
+	Skip []string
+	// Ignore lists glob patterns, as understood by filepath.Match, which
+	// are matched against directory basenames and full paths. Matching
+	// directories are not indexed or descended into
+	Ignore []string
+	// MaxDepth limits how many levels below the root are indexed. 0 means
+	// no limit
+	MaxDepth int
+	// MaxDirs caps the number of directories indexed by the walk. 0 means
+	// no limit
+	MaxDirs int
+	// Timeout is the time budget for the walk. 0 means no limit
+	Timeout time.Duration
+	// OneFileSystem keeps the walk on the root's filesystem
+	OneFileSystem bool
+	// FollowSymlinks descends into symlinked directories once everything
+	// reachable without them has been walked. Directories which are
+	// reachable through more than one path are only indexed once, by the
+	// first path found in that order
+	FollowSymlinks bool
+	// CanonicalPaths stores directories with all symlinks resolved rather
+	// than as they were visited
+	CanonicalPaths bool
+	// Markers lists the names of files or directories, such as go.mod,
+	// whose presence makes the directory holding them a project root
+	Markers []string
+}
+
+// WalkStats describes the outcome of a walk
+type WalkStats struct {
+	Root       string
+	Start      time.Time
+	Duration   time.Duration
+	Dirs       int
+	DepthSkips int
+	DupSkips   int
+	XdevSkips  int
+	// Added counts the directories which were new to the index
+	Added int
+	// ErrorSkips counts directories which couldn't be read
+	ErrorSkips int
+	// SkipHits counts the directories skipped by each skip list entry,
+	// ignore pattern and blocklist pattern
+	SkipHits map[string]int
+	// Truncated explains why the walk did not index everything below the
+	// root. It is empty for a complete walk
+	Truncated string
+}
+
+// fileID identifies a directory by its device and inode
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// statID returns the fileID of path, following any symlinks
+func statID(path string) (fileID, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, fmt.Errorf("unable to determine device for %s", path)
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+}
+
+// relativeDepth returns how many levels below root path is
+func relativeDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// walker holds the state of a single walk
+type walker struct {
+	*filter
+	ctx     context.Context
+	idx     *Index
+	markers map[string]struct{}
+	links   map[string]string
+	seen    map[fileID]string
+	stats   WalkStats
+	// found records every directory indexed by the walk, when it isn't nil
+	found map[string]struct{}
+	// unreadable lists the directories whose contents couldn't be read
+	unreadable []string
+	// halted is set when the walk stopped before reaching every directory
+	halted bool
+	// deferred lists the symlinked directories to walk once everything
+	// reachable without them has been
+	deferred []string
+}
+
+// canonicalPath rewrites path using the longest symlink seen during the
+// walk that prefixes it
+func (w *walker) canonicalPath(path string) string {
+	var link string
+	for l := range w.links {
+		if (path == l || strings.HasPrefix(path, l+"/")) && len(l) > len(link) {
+			link = l
+		}
+	}
+	if link == "" {
+		return path
+	}
+	return w.links[link] + strings.TrimPrefix(path, link)
+}
+
+// callback is the func passed to godirwalk.Walk for creating new pathCandidates
+func (w *walker) callback(path string, de *godirwalk.Dirent) error {
+	if _, ok := w.markers[filepath.Base(path)]; ok {
+		// The directory holding the marker has already been indexed as
+		// directories are visited before their contents
+		dir := filepath.Dir(path)
+		if w.opts.CanonicalPaths {
+			dir = w.canonicalPath(dir)
+		}
+		w.idx.mux.Lock()
+		w.idx.setProject(dir, true)
+		w.idx.mux.Unlock()
+	}
+	if w.opts.FollowSymlinks && de.IsSymlink() {
+		isDir, err := de.IsDirOrSymlinkToDir()
+		if err != nil || !isDir {
+			return nil
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return nil
+		}
+		w.links[path] = target
+		w.deferred = append(w.deferred, path)
+		return filepath.SkipDir
+	} else if !de.IsDir() {
+		return nil
+	}
+	if w.ctx.Err() != nil {
+		return errWalkCancel
+	}
+	if w.opts.Timeout > 0 && time.Since(w.stats.Start) > w.opts.Timeout {
+		return errWalkTimeout
+	}
+	if w.opts.MaxDirs > 0 && w.stats.Dirs >= w.opts.MaxDirs {
+		return errMaxDirs
+	}
+	var id fileID
+	if w.opts.OneFileSystem || w.opts.FollowSymlinks {
+		var err error
+		id, err = statID(path)
+		if err != nil {
+			return filepath.SkipDir
+		}
+	}
+	switch reason, rule := w.excludes(path, id); reason {
+	case included:
+	case tooDeep:
+		w.stats.DepthSkips++
+		return filepath.SkipDir
+	case otherFilesystem:
+		log.Debugln("Skipping", path, "as it is not on the root filesystem")
+		w.stats.XdevSkips++
+		return filepath.SkipDir
+	case blocked:
+		log.Debugf("Skipping %s as it matches blocklist pattern %s\n", path, rule)
+		w.stats.SkipHits[rule]++
+		return filepath.SkipDir
+	case ignored:
+		log.Debugf("Ignoring %s as it matches %s\n", path, rule)
+		w.stats.SkipHits[rule]++
+		return filepath.SkipDir
+	default:
+		log.Debugln("Skipping", path)
+		w.stats.SkipHits[rule]++
+		return filepath.SkipDir
+	}
+	if w.opts.FollowSymlinks {
+		if seen, ok := w.seen[id]; ok {
+			log.Debugf("Skipping %s as it has already been indexed as %s\n", path, seen)
+			w.stats.DupSkips++
+			return filepath.SkipDir
+		}
+		w.seen[id] = path
+	}
+	if w.opts.CanonicalPaths {
+		path = w.canonicalPath(path)
+	}
+	w.idx.mux.Lock()
+	w.stats.Dirs++
+	if w.idx.addPath(path) {
+		w.stats.Added++
+	}
+	// Whether path is still a project root is decided by its contents
+	w.idx.setProject(path, false)
+	w.idx.mux.Unlock()
+	if w.found != nil {
+		w.found[path] = struct{}{}
+	}
+	return nil
+}
+
+// Walking returns the progress of the walks which are running. Only Root,
+// Start, Duration and Dirs are filled in
+func (i *Index) Walking() []WalkStats {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	var walks []WalkStats
+	for w := range i.walking {
+		walks = append(walks, WalkStats{
+			Root:     w.stats.Root,
+			Start:    w.stats.Start,
+			Duration: time.Since(w.stats.Start),
+			Dirs:     w.stats.Dirs,
+		})
+	}
+	sort.Slice(walks, func(a, b int) bool {
+		return walks[a].Start.Before(walks[b].Start)
+	})
+	return walks
+}
+
+// walk adds every directory below root to the index. The index is only
+// locked while each directory is added, so it can be queried during the
+// walk. A walk which hits one of the limits in opts, or whose ctx is
+// canceled, is truncated rather than failed, and the reason is recorded in
+// the returned WalkStats
+func (i *Index) walk(ctx context.Context, root string, opts WalkOptions) (WalkStats, error) {
+	w, err := i.scan(ctx, root, root, opts, false)
+	return w.stats, err
+}
+
+// scan walks the directories below start, which must be root or below it,
+// applying the options of root. Depth is counted from root. When track is
+// set every directory indexed is recorded in the walker's found set
+func (i *Index) scan(ctx context.Context, root, start string, opts WalkOptions, track bool) (*walker, error) {
+	root = filepath.Clean(root)
+	start = filepath.Clean(start)
+	w := &walker{
+		ctx:   ctx,
+		idx:   i,
+		links: make(map[string]string),
+		seen:  make(map[fileID]string),
+		stats: WalkStats{Root: start, Start: time.Now(), SkipHits: make(map[string]int)},
+	}
+	if track {
+		w.found = make(map[string]struct{})
+	}
+	w.markers = make(map[string]struct{})
+	for _, m := range opts.Markers {
+		w.markers[m] = struct{}{}
+	}
+	i.mux.Lock()
+	block := append([]string(nil), i.blocklist...)
+	i.mux.Unlock()
+	f, err := newFilter(root, opts, block)
+	if err != nil {
+		return w, err
+	}
+	w.filter = f
+	i.mux.Lock()
+	i.walking[w] = struct{}{}
+	i.mux.Unlock()
+	defer func() {
+		i.mux.Lock()
+		delete(i.walking, w)
+		i.mux.Unlock()
+	}()
+	if opts.CanonicalPaths {
+		abs, err := filepath.Abs(start)
+		if err != nil {
+			return w, err
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			w.links[start] = resolved
+		}
+	}
+	walkOpts := &godirwalk.Options{
+		Callback:            w.callback,
+		FollowSymbolicLinks: opts.FollowSymlinks,
+		ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
+			if err == errMaxDirs || err == errWalkTimeout || err == errWalkCancel {
+				return godirwalk.Halt
+			}
+			log.Debugf("Skipping %s: %v\n", osPathname, err)
+			w.stats.ErrorSkips++
+			w.unreadable = append(w.unreadable, osPathname)
+			return godirwalk.SkipNode
+		},
+		Unsorted: true,
+	}
+	err = godirwalk.Walk(start, walkOpts)
+	// Symlinked directories are walked in order once everything reachable
+	// without them has been, so a directory reachable both ways is always
+	// indexed by the same path, preferring the one without symlinks
+	for err == nil && len(w.deferred) > 0 {
+		links := w.deferred
+		w.deferred = nil
+		sort.Strings(links)
+		for _, link := range links {
+			err = godirwalk.Walk(link, walkOpts)
+			if err == errMaxDirs || err == errWalkTimeout || err == errWalkCancel {
+				break
+			}
+			if err != nil {
+				log.Debugf("Skipping %s: %v\n", link, err)
+				w.stats.ErrorSkips++
+				w.unreadable = append(w.unreadable, link)
+				err = nil
+			}
+		}
+	}
+	w.stats.Duration = time.Now().Sub(w.stats.Start)
+	switch err {
+	case nil:
+	case errMaxDirs, errWalkTimeout, errWalkCancel:
+		w.stats.Truncated = err.Error()
+		w.halted = true
+	default:
+		return w, err
+	}
+	if w.stats.Truncated == "" && w.stats.DepthSkips > 0 {
+		w.stats.Truncated = fmt.Sprintf("%d directories beyond max depth %d", w.stats.DepthSkips, opts.MaxDepth)
+	}
+	if w.stats.Truncated == "" && w.stats.XdevSkips > 0 {
+		w.stats.Truncated = fmt.Sprintf("%d directories on other filesystems", w.stats.XdevSkips)
+	}
+	if w.stats.DupSkips > 0 {
+		log.Debugf("Merged %d directories reachable through more than one path\n", w.stats.DupSkips)
+	}
+	return w, nil
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"github.com/walkert/ceedee/client"
+	"github.com/walkert/ceedee/index"
 	"github.com/walkert/ceedee/server"
 )
 
@@ -21,24 +25,80 @@ const (
 )
 
 var (
-	daemonArgs = "--daemon -d"
+	// version is set at build time with -ldflags "-X main.version=..."
+	version = "dev"
 )
 
+// options holds the values of every command line flag
+type options struct {
+	home             string
+	asServer         bool
+	autoStart        bool
+	autoStartTimeout time.Duration
+	block            bool
+	blocklistFile    string
+	configFile       string
+	configRequired   bool
+	daemonMode       bool
+	dirInterval      int
+	fallback         bool
+	fallbackTimeout  time.Duration
+	followSymlinks   bool
+	format           string
+	from             string
+	histFile         string
+	ignore           string
+	list             bool
+	logFile          string
+	maxDepth         int
+	maxDirs          int
+	missBudget       time.Duration
+	monitorInterval  int
+	pathStyle        string
+	pidFile          string
+	port             int
+	profile          string
+	profiles         map[string]map[string]interface{}
+	projectMarkers   string
+	reindexTimeout   time.Duration
+	retries          int
+	retryBackoff     time.Duration
+	root             string
+	showVersion      bool
+	shutdownTimeout  time.Duration
+	skipDirs         string
+	snapshotFile     string
+	socket           string
+	sources          map[string]string
+	tag              string
+	tcp              bool
+	timeout          time.Duration
+	tlsCert          string
+	tlsKey           string
+	tokenFile        string
+	useTLS           bool
+	verbose          bool
+	walkTimeout      time.Duration
+	weights          index.Weights
+	xdev             bool
+}
+
 func main() {
 	home, err := homedir.Dir()
 	if err != nil {
 		log.Fatalln("Unable to determine home directory")
 	}
-	asServer := flag.Bool("server", false, "run in server mode")
-	daemonMode := flag.BoolP("daemon", "d", false, "deamonize when running in server mode")
-	histFile := flag.String("hist-file", filepath.Join(home, zhistDefault), "the history file to search")
-	list := flag.BoolP("list", "l", false, "list all matching directories")
-	port := flag.Int("port", 2020, "connect/listen to this port")
-	skipDirs := flag.String("skip-dirs", ".git,.hg", "a comma-separated list of directories to skip while indexing")
-	root := flag.String("root", "", "the path to index")
-	verbose := flag.Bool("verbose", false, "enable verbose logging")
+	confDir := configDir(home)
+	o := &options{home: home}
+	defineFlags(flag.CommandLine, o, confDir)
 	flag.Parse()
-	if *verbose {
+	o.configRequired = flag.CommandLine.Changed("config")
+	if value, ok := os.LookupEnv(envName("config")); ok && !o.configRequired {
+		o.configFile, o.configRequired = value, true
+	}
+	o.sources = cmdlineSources(flag.CommandLine)
+	o.profiles, err = applyConfig(flag.CommandLine, o.configFile, home, o.configRequired, o.sources)
+	if o.verbose {
 		log.SetLevel(log.DebugLevel)
 	} else {
 		log.SetLevel(log.InfoLevel)
@@ -48,73 +108,479 @@ func main() {
 		TimestampFormat:        "2006-01-02 15:04:05",
 		DisableLevelTruncation: true,
 	})
-	if !*asServer {
-		if len(flag.Args()) == 0 {
-			log.Fatal("No directory supplied")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if o.showVersion {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+	if filepath.Dir(o.socket) == client.RuntimeDir() || filepath.Dir(o.pidFile) == client.RuntimeDir() {
+		if err := client.CheckRuntimeDir(); err != nil {
+			log.Fatalln(err)
 		}
-		c, err := client.New(*port)
-		if err != nil {
+	}
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) < 2 || args[1] != "show" {
+			log.Fatalln("Usage: ceedee config show")
+		}
+		showConfig(os.Stdout, flag.CommandLine, o.sources)
+		return
+	}
+	if len(args) > 0 && args[0] == "status" {
+		if err := showStatus(o, os.Stdout); err != nil {
 			log.Fatal(err)
 		}
-		values, err := c.Get(flag.Args()[0])
-		if err != nil {
-			if strings.Contains(err.Error(), "refused") {
-				log.Fatalln("There is no server listening on port", *port)
-			}
+		return
+	}
+	if len(args) > 0 && args[0] == "export" {
+		if len(args) != 1 {
+			log.Fatalln("Usage: ceedee export [--format json|csv|z]")
+		}
+		if err := exportIndex(o, os.Stdout, o.format); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "import" {
+		if o.from == "" || len(args) > 2 {
+			log.Fatalln("Usage: ceedee import --from z|autojump|fasd|zoxide [file]")
+		}
+		var path string
+		if len(args) == 2 {
+			path = args[1]
+		}
+		if err := importDatabase(o, o.from, path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && (args[0] == "mark" || args[0] == "unmark" || args[0] == "marks") {
+		if err := markCommand(o, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "tag" {
+		if err := tagCommand(o, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "list" {
+		if len(args) != 1 {
+			log.Fatalln("Usage: ceedee list [--tag <tag>]")
+		}
+		if err := listPaths(o, o.tag); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "forget" {
+		if len(args) != 2 {
+			log.Fatalln("Usage: ceedee forget [--block] <path|pattern>")
+		}
+		if err := forget(o, args[1]); err != nil {
 			log.Fatal(err)
 		}
-		if len(values) == 0 {
-			os.Exit(1)
+		return
+	}
+	if len(args) > 0 && args[0] == "reindex" {
+		if len(args) > 2 {
+			log.Fatalln("Usage: ceedee reindex [path]")
+		}
+		var path string
+		if len(args) == 2 {
+			path = args[1]
 		}
-		if *list {
-			for _, entry := range values {
-				fmt.Println(strings.Split(entry, ";")[1])
+		if err := reindex(o, path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "server" {
+		if len(args) < 2 {
+			log.Fatalln("Usage: ceedee server start|stop|status|restart|reload|run")
+		}
+		serverCommand(o, args[1])
+		return
+	}
+	if o.asServer {
+		if o.daemonMode {
+			serverCommand(o, "start")
+		} else {
+			serverCommand(o, "run")
+		}
+		return
+	}
+	if len(args) == 0 {
+		log.Fatal("No directory supplied")
+	}
+	// Several arguments form a single query such as 'api @backend'
+	lookup(o, strings.Join(args, " "))
+}
+
+// defineFlags defines every flag on fs, storing their values in o. confDir
+// is the directory holding the default config, token and TLS files
+func defineFlags(fs *flag.FlagSet, o *options, confDir string) {
+	home := o.home
+	fs.BoolVar(&o.asServer, "server", false, "run in server mode")
+	fs.BoolVar(&o.autoStart, "autostart", false, "start the daemon if no server is listening")
+	fs.DurationVar(&o.autoStartTimeout, "autostart-timeout", 10*time.Second, "how long to wait for an automatically started daemon")
+	fs.BoolVar(&o.block, "block", false, "with 'forget', also add the path or pattern to the blocklist")
+	fs.StringVar(&o.blocklistFile, "blocklist-file", filepath.Join(confDir, "blocklist"), "the file listing paths and patterns which are never indexed")
+	fs.StringVar(&o.configFile, "config", filepath.Join(confDir, "config.toml"), "the config file")
+	fs.BoolVarP(&o.daemonMode, "daemon", "d", false, "deamonize when running in server mode (same as 'server start')")
+	fs.IntVar(&o.dirInterval, "dir-interval", 1, "how often, in hours, the server walks the roots again")
+	fs.BoolVar(&o.fallback, "fallback", true, "answer from the index snapshot or a quick walk when no server is listening")
+	fs.DurationVar(&o.fallbackTimeout, "fallback-timeout", 2*time.Second, "the time budget for the fallback walk")
+	fs.BoolVar(&o.followSymlinks, "follow-symlinks", false, "descend into symlinked directories while indexing")
+	fs.StringVar(&o.format, "format", "json", "the format written by 'export': json, csv or z")
+	fs.StringVar(&o.from, "from", "", "the directory jumper whose database 'import' reads: z, autojump, fasd or zoxide")
+	fs.StringVar(&o.histFile, "hist-file", filepath.Join(home, zhistDefault), "the history file to search")
+	fs.StringVar(&o.ignore, "ignore", "", "a comma-separated list of glob patterns for directories to skip while indexing")
+	fs.BoolVarP(&o.list, "list", "l", false, "list all matching directories")
+	fs.StringVar(&o.logFile, "log-file", filepath.Join(stateDir(home), "ceedee.log"), "the file the daemon logs to")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "the maximum depth below the root to index (0 for no limit)")
+	fs.IntVar(&o.maxDirs, "max-dirs", 0, "the maximum number of directories to index per walk (0 for no limit)")
+	fs.DurationVar(&o.missBudget, "miss-budget", 0, "how long the server may search for a name it doesn't know before giving up (0 to not search)")
+	fs.IntVar(&o.monitorInterval, "monitor-interval", 10, "how often, in seconds, the server checks the history file for changes")
+	fs.StringVar(&o.pathStyle, "path-style", "visited", "how to spell directories reached through symlinks: 'visited' or 'canonical'")
+	fs.StringVar(&o.pidFile, "pid-file", filepath.Join(client.RuntimeDir(), "ceedee.pid"), "the daemon's pid file")
+	fs.IntVar(&o.port, "port", client.DefaultPort, "connect/listen to this port")
+	fs.StringVar(&o.profile, "profile", "", "the profile to use, as set up under [profiles.<name>] in the config file")
+	fs.StringVar(&o.projectMarkers, "project-markers", strings.Join(index.DefaultMarkers, ","), "a comma-separated list of files or directories which mark a project root")
+	fs.IntVar(&o.retries, "retries", 0, "how many times to retry a request while the server is unavailable")
+	fs.DurationVar(&o.retryBackoff, "retry-backoff", 200*time.Millisecond, "how long to wait between retries")
+	fs.StringVar(&o.socket, "socket", client.DefaultSocket(), "connect/listen to this unix domain socket")
+	fs.StringVar(&o.snapshotFile, "snapshot-file", filepath.Join(stateDir(home), "index.json"), "where the server saves a snapshot of its index")
+	fs.DurationVar(&o.reindexTimeout, "reindex-timeout", 10*time.Minute, "how long 'ceedee reindex' waits for the walk to finish")
+	fs.DurationVar(&o.shutdownTimeout, "shutdown-timeout", 5*time.Second, "how long the server waits for in-flight requests when shutting down")
+	fs.StringVar(&o.skipDirs, "skip-dirs", ".git,.hg", "a comma-separated list of directories to skip while indexing")
+	fs.StringVar(&o.root, "root", "", "the path to index (a comma-separated list for several roots)")
+	fs.StringVar(&o.tag, "tag", "", "with 'list', only list directories with this tag")
+	fs.BoolVar(&o.tcp, "tcp", false, "use the TCP port rather than the unix domain socket")
+	fs.DurationVar(&o.timeout, "timeout", 5*time.Second, "how long to wait for the server to answer")
+	fs.BoolVar(&o.useTLS, "tls", false, "use TLS when connecting/listening over TCP")
+	fs.StringVar(&o.tlsCert, "tls-cert", filepath.Join(confDir, "cert.pem"), "the TLS certificate (generated by the server if missing)")
+	fs.StringVar(&o.tlsKey, "tls-key", filepath.Join(confDir, "key.pem"), "the TLS key (generated by the server if missing)")
+	fs.StringVar(&o.tokenFile, "token-file", filepath.Join(confDir, "token"), "the shared-secret token used over TCP (empty to disable)")
+	fs.BoolVar(&o.verbose, "verbose", false, "enable verbose logging")
+	fs.BoolVar(&o.showVersion, "version", false, "print the version and exit")
+	fs.DurationVar(&o.walkTimeout, "walk-timeout", 0, "the time budget for each directory walk (0 for no limit)")
+	fs.Float64Var(&o.weights.History, "weight-history", index.DefaultWeights.History, "the rank a directory gains for each 'cd' to it in the history")
+	fs.Float64Var(&o.weights.Visit, "weight-visit", index.DefaultWeights.Visit, "the rank a directory gains for each recorded visit")
+	fs.Float64Var(&o.weights.Depth, "weight-depth", index.DefaultWeights.Depth, "the rank a directory loses for each level of depth")
+	fs.Float64Var(&o.weights.Proximity, "weight-proximity", index.DefaultWeights.Proximity, "the rank a directory gains for each leading path element it shares with the current directory")
+	fs.Float64Var(&o.weights.Project, "weight-project", index.DefaultWeights.Project, "the rank a directory gains for being a project root")
+	fs.Float64Var(&o.weights.Repo, "weight-repo", index.DefaultWeights.Repo, "the rank a directory gains for being in the same repository as the current directory")
+	fs.BoolVar(&o.xdev, "xdev", false, "do not index directories on filesystems other than the root's")
+}
+
+// newClient returns a client which uses the unix domain socket when it
+// exists and the TCP port otherwise. The bool reports whether the socket
+// is in use
+func newClient(o *options) (*client.Client, bool, error) {
+	opts := []client.Opt{client.WithTimeout(o.timeout)}
+	if cwd, err := os.Getwd(); err == nil {
+		opts = append(opts, client.WithCwd(cwd))
+	}
+	if o.retries > 0 {
+		opts = append(opts, client.WithRetry(o.retries+1, o.retryBackoff))
+	}
+	if o.profile != "" {
+		opts = append(opts, client.WithProfile(o.profile))
+	}
+	useSocket := false
+	if _, err := os.Stat(o.socket); err == nil && !o.tcp {
+		opts = append(opts, client.WithSocket(o.socket))
+		useSocket = true
+	} else {
+		opts = append(opts, client.WithAddress(fmt.Sprintf("localhost:%d", o.port)))
+		if _, err := os.Stat(o.tokenFile); err == nil {
+			opts = append(opts, client.WithTokenFile(o.tokenFile))
+		}
+		if o.useTLS {
+			opts = append(opts, client.WithTLS(o.tlsCert))
+		}
+	}
+	c, err := client.New(opts...)
+	return c, useSocket, err
+}
+
+// lookup asks the server for dir and prints the results
+func lookup(o *options, dir string) {
+	c, useSocket, err := newClient(o)
+	if err != nil {
+		log.Fatal(err)
+	}
+	values, incomplete, err := c.Lookup(context.Background(), dir)
+	if err != nil && o.autoStart && notListening(err) {
+		if startErr := autoStart(o); startErr != nil {
+			log.Infoln("Unable to start the server:", startErr)
+		} else {
+			c.Close()
+			c, useSocket, err = newClient(o)
+			if err != nil {
+				log.Fatal(err)
 			}
-			os.Exit(0)
+			values, incomplete, err = c.Lookup(context.Background(), dir)
 		}
-		if strings.HasPrefix(values[0], "e") {
-			fmt.Println(strings.Split(values[0], ";")[1])
-			os.Exit(0)
+	}
+	c.Close()
+	if err != nil && o.fallback && notListening(err) {
+		fo := o
+		if o.profile != "" && o.profile != server.DefaultProfile {
+			if fo, err = profileOptions(flag.CommandLine, o, o.profile); err != nil {
+				log.Fatal(err)
+			}
 		}
-		if strings.HasPrefix(values[0], "p") {
-			for _, partial := range values {
-				fmt.Println(strings.Split(partial, ";")[1])
+		values, err = fallbackLookup(fo, dir), nil
+	}
+	if err != nil {
+		if notListening(err) {
+			if useSocket {
+				log.Fatalln("There is no server listening on socket", o.socket)
 			}
-			os.Exit(0)
-		}
-	}
-	if *asServer {
-		if *root == "" {
-			log.Fatalln("You must enter a root path")
-		}
-		if *daemonMode {
-			prog := path.Base(os.Args[0])
-			binary, _ := exec.LookPath(os.Args[0])
-			args := []string{binary}
-			for _, arg := range os.Args[1:] {
-				if strings.Contains(daemonArgs, arg) {
-					continue
-				}
-				args = append(args, arg)
+			log.Fatalln("There is no server listening on port", o.port)
+		}
+		log.Fatal(err)
+	}
+	if incomplete {
+		log.Debugln("The server is still building its index")
+	}
+	if len(values) == 0 {
+		if incomplete {
+			log.Infoln("No match yet, the server is still building its index")
+		}
+		os.Exit(1)
+	}
+	if o.list {
+		for _, entry := range values {
+			fmt.Println(strings.Split(entry, ";")[1])
+		}
+		os.Exit(0)
+	}
+	if strings.HasPrefix(values[0], "e") || strings.HasPrefix(values[0], "b") {
+		fmt.Println(strings.Split(values[0], ";")[1])
+		os.Exit(0)
+	}
+	if strings.HasPrefix(values[0], "p") {
+		for _, partial := range values {
+			fmt.Println(strings.Split(partial, ";")[1])
+		}
+		os.Exit(0)
+	}
+}
+
+// forget asks the server to remove path, which may be a pattern, from its
+// index, and to block it from being indexed again if --block was given. A
+// leading '~' is expanded and a relative path is taken to be relative to
+// the current directory
+func forget(o *options, path string) error {
+	abs, err := absPath(o, path)
+	if err != nil {
+		return err
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	var removed int
+	if o.block {
+		removed, err = c.Block(context.Background(), abs)
+	} else {
+		removed, err = c.Forget(context.Background(), abs)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to forget %s: %v", abs, err)
+	}
+	fmt.Printf("Removed %d entries\n", removed)
+	if o.block {
+		fmt.Printf("Added %s to the blocklist\n", abs)
+	}
+	return nil
+}
+
+// reindex asks the server to walk path, or every root if it is empty, and
+// reports what changed. A relative path is taken to be relative to the
+// current directory
+func reindex(o *options, path string) error {
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), o.reindexTimeout)
+	defer cancel()
+	reply, err := c.Reindex(ctx, path)
+	if err != nil {
+		return fmt.Errorf("unable to reindex: %v", err)
+	}
+	fmt.Printf("Added %d and removed %d directories\n", reply.Added, reply.Removed)
+	if reply.Truncated {
+		fmt.Println("A walk was cut short, so no directories were removed")
+	}
+	return nil
+}
+
+// notListening reports whether err means that no server is listening
+func notListening(err error) bool {
+	return strings.Contains(err.Error(), "refused") || strings.Contains(err.Error(), "no such file")
+}
+
+// serverOpts converts the command line options into server options, with
+// a WithProfile for every profile in the config file
+func serverOpts(o *options) ([]server.Opt, error) {
+	opts := []server.Opt{server.WithSocket(o.socket)}
+	if o.tcp {
+		opts = []server.Opt{server.WithPort(o.port)}
+		if o.tokenFile != "" {
+			opts = append(opts, server.WithTokenFile(o.tokenFile))
+		}
+		if o.useTLS {
+			opts = append(opts, server.WithTLS(o.tlsCert, o.tlsKey))
+		}
+	}
+	opts = append(opts, indexOpts(o)...)
+	opts = append(opts,
+		server.WithVersion(version),
+		server.WithReloadFunc(func() ([]server.Opt, error) {
+			if err := reloadConfig(flag.CommandLine, o); err != nil {
+				return nil, err
 			}
-			cmdEnv := os.Environ()
-			pid, err := syscall.ForkExec(binary, args, &syscall.ProcAttr{Env: cmdEnv})
-			if err != nil {
-				log.Fatalf("ERROR: unable to start %s in daemon mode: %v\n", prog, err)
+			return serverOpts(o)
+		}),
+	)
+	var names []string
+	for name := range o.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		po, err := profileOptions(flag.CommandLine, o, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkIndexOpts(po); err != nil {
+			return nil, fmt.Errorf("profile %s: %v", name, err)
+		}
+		opts = append(opts, server.WithProfile(name, indexOpts(po)...))
+	}
+	return opts, nil
+}
+
+// indexOpts converts the options which describe a profile's index into
+// server options
+func indexOpts(o *options) []server.Opt {
+	return []server.Opt{
+		server.WithRoots(splitList(o.root)),
+		server.WithSkipList(splitList(o.skipDirs)),
+		server.WithIgnore(splitList(o.ignore)),
+		server.WithProjectMarkers(splitList(o.projectMarkers)),
+		server.WithWeights(o.weights),
+		server.WithMonitorInterval(o.monitorInterval),
+		server.WithDirInterval(o.dirInterval),
+		server.WithHistFile(o.histFile),
+		server.WithHome(o.home),
+		server.WithMaxDepth(o.maxDepth),
+		server.WithMaxDirs(o.maxDirs),
+		server.WithMissBudget(o.missBudget),
+		server.WithBlocklist(o.blocklistFile),
+		server.WithWalkTimeout(o.walkTimeout),
+		server.WithOneFileSystem(o.xdev),
+		server.WithFollowSymlinks(o.followSymlinks),
+		server.WithCanonicalPaths(o.pathStyle == "canonical"),
+		server.WithSnapshot(o.snapshotFile),
+	}
+}
+
+// checkIndexOpts returns an error if o doesn't describe an index the
+// server can build
+func checkIndexOpts(o *options) error {
+	if o.root == "" {
+		return errors.New("You must enter a root path")
+	}
+	if o.pathStyle != "visited" && o.pathStyle != "canonical" {
+		return errors.New("The path style must be one of 'visited' or 'canonical'")
+	}
+	return nil
+}
+
+// runServer runs the server in the foreground until it receives SIGINT or
+// SIGTERM, then shuts it down gracefully
+func runServer(o *options) {
+	if err := checkIndexOpts(o); err != nil {
+		log.Fatalln(err)
+	}
+	opts, err := serverOpts(o)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	lis, err := server.SystemdListener()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if lis != nil {
+		log.Infoln("Using the socket passed in by systemd:", lis.Addr())
+		opts = append(opts, server.WithListener(lis))
+	}
+	s, err := server.New(opts...)
+	if err != nil {
+		log.Fatalln("Unable to create a new server instance:", err)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Infoln("Received SIGHUP, reloading the configuration")
+			if _, err := s.Reload(); err != nil {
+				log.Infoln(err)
 			}
-			fmt.Printf("Started %s in daemon mode with pid %d\n", prog, pid)
-			os.Exit(0)
-		}
-		s, err := server.New(
-			server.WithPort(*port),
-			server.WithRoot(*root),
-			server.WithSkipList(strings.Split(*skipDirs, ",")),
-			server.WithHistFile(*histFile),
-			server.WithHome(home),
-		)
+		}
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Start()
+	}()
+	select {
+	case err := <-served:
 		if err != nil {
-			log.Fatalln("Unable to create a new server instance:", err)
+			log.Fatalln(err)
+		}
+	case sig := <-stop:
+		log.Infof("Received %s, shutting down\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Infoln("Unable to shut down cleanly:", err)
 		}
-		s.Start()
 	}
 }
+
+// configDir returns the directory which holds ceedee's configuration
+func configDir(home string) string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ceedee")
+	}
+	return filepath.Join(home, ".config", "ceedee")
+}
+
+// stateDir returns the directory which holds ceedee's logs and other state
+func stateDir(home string) string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ceedee")
+	}
+	return filepath.Join(home, ".local", "state", "ceedee")
+}
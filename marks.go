@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// absPath expands a leading '~' in path and makes it absolute
+func absPath(o *options, path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(o.home, strings.TrimPrefix(path, "~"))
+	}
+	return filepath.Abs(path)
+}
+
+// markCommand runs the mark, unmark and marks commands which manage the
+// server's bookmarks
+func markCommand(o *options, args []string) error {
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx := context.Background()
+	switch {
+	case args[0] == "mark" && (len(args) == 2 || len(args) == 3):
+		path := "."
+		if len(args) == 3 {
+			path = args[2]
+		}
+		abs, err := absPath(o, path)
+		if err != nil {
+			return err
+		}
+		if err := c.Mark(ctx, args[1], abs); err != nil {
+			return fmt.Errorf("unable to bookmark %s: %v", abs, err)
+		}
+		fmt.Printf("Bookmarked %s as %s\n", abs, args[1])
+	case args[0] == "unmark" && len(args) == 2:
+		if err := c.Unmark(ctx, args[1]); err != nil {
+			return fmt.Errorf("unable to remove bookmark %s: %v", args[1], err)
+		}
+	case args[0] == "marks" && len(args) == 1:
+		marks, err := c.Marks(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list bookmarks: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, m := range marks {
+			fmt.Fprintf(w, "%s\t%s\n", m.Name, m.Path)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("Usage: ceedee mark <name> [path] | unmark <name> | marks")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readBlocklist returns the patterns listed in path, one per line. Blank
+// lines and lines starting with '#' are ignored, and a missing file is an
+// empty blocklist
+func readBlocklist(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// appendBlocklist adds pattern to the end of the file at path, leaving
+// whatever is already in it untouched. A new file starts with a comment
+// describing it
+func appendBlocklist(path, pattern string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if len(existing) == 0 {
+		fmt.Fprintln(w, "# Paths and patterns which ceedee never indexes, one per line")
+	} else if existing[len(existing)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, pattern)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// historyTailer follows a shell history file, returning the lines appended
+// to it since it was last read
+type historyTailer struct {
+	path   string
+	offset int64
+}
+
+// newHistoryTailer returns a historyTailer which will read path from the
+// start
+func newHistoryTailer(path string) (*historyTailer, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &historyTailer{path: path}, nil
+}
+
+// poll returns every complete line appended to the file since the last
+// call. A line still being written is left for the next call. A file which
+// has shrunk is assumed to have been rewritten and is read from the start
+func (t *historyTailer) poll() ([]byte, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < t.offset {
+		t.offset = 0
+	}
+	if fi.Size() == t.offset {
+		return nil, nil
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(f, fi.Size()-t.offset))
+	if err != nil {
+		return nil, err
+	}
+	end := bytes.LastIndexByte(b, '\n')
+	if end < 0 {
+		return nil, nil
+	}
+	b = b[:end+1]
+	t.offset += int64(len(b))
+	return b, nil
+}
+
+// readOffsets returns the history offsets saved in path by writeOffsets
+func readOffsets(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	offsets := make(map[string]int64)
+	if err := json.NewDecoder(f).Decode(&offsets); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// writeOffsets atomically replaces the file at path with the offset of
+// each history file that has been read
+func writeOffsets(path string, offsets map[string]int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".offsets")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(offsets); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	pb "github.com/walkert/ceedee/ceedeeproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultProfile names the profile configured by the options given to New
+// directly rather than through WithProfile. Calls which don't name a
+// profile use it
+const DefaultProfile = "default"
+
+// profileConfig holds the options of a profile given with WithProfile
+type profileConfig struct {
+	name string
+	opts []Opt
+}
+
+// WithProfile hosts a further profile called name alongside the default
+// one. A profile has its own roots, history file, skip rules, blocklist,
+// weights and snapshot, and so its own ranking. Only the options which
+// describe the index apply to a profile; those describing how the server
+// listens are taken from the default profile
+func WithProfile(name string, opts ...Opt) Opt {
+	return func(s *Server) {
+		s.profileOpts = append(s.profileOpts, profileConfig{name: name, opts: opts})
+	}
+}
+
+// all returns the default profile followed by every other profile, sorted
+// by name
+func (s *Server) all() []*Server {
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	all := []*Server{s}
+	for _, name := range names {
+		all = append(all, s.profiles[name])
+	}
+	return all
+}
+
+// profileNames returns the name of every profile, the default first
+func (s *Server) profileNames() []string {
+	var names []string
+	for _, p := range s.all() {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// router implements the CeeDee service by passing each call on to the
+// profile named in its metadata
+type router struct {
+	svr *Server
+}
+
+// profile returns the profile named in the metadata of ctx, or the default
+// profile if none is named
+func (r *router) profile(ctx context.Context) (*Server, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(pb.ProfileKey)
+	if len(values) == 0 || values[0] == "" || values[0] == DefaultProfile {
+		return r.svr, nil
+	}
+	if p, ok := r.svr.profiles[values[0]]; ok {
+		return p, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "no profile named %s", values[0])
+}
+
+func (r *router) Get(ctx context.Context, d *pb.Directory) (*pb.Dlist, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.Dlist{}, err
+	}
+	return p.cs.Get(ctx, d)
+}
+
+// Status reports on the profile named in the call along with the names of
+// every profile
+func (r *router) Status(ctx context.Context, v *pb.Void) (*pb.ServerStatus, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.ServerStatus{}, err
+	}
+	st, err := p.cs.Status(ctx, v)
+	if err != nil {
+		return st, err
+	}
+	st.Profile = p.name
+	st.Profiles = r.svr.profileNames()
+	return st, nil
+}
+
+func (r *router) Visit(ctx context.Context, path *pb.Path) (*pb.Void, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.Void{}, err
+	}
+	return p.cs.Visit(ctx, path)
+}
+
+func (r *router) List(req *pb.ListRequest, stream pb.CeeDee_ListServer) error {
+	p, err := r.profile(stream.Context())
+	if err != nil {
+		return err
+	}
+	return p.cs.List(req, stream)
+}
+
+func (r *router) Forget(ctx context.Context, req *pb.ForgetRequest) (*pb.ForgetReply, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.ForgetReply{}, err
+	}
+	return p.cs.Forget(ctx, req)
+}
+
+// Reload reloads every profile, whichever one the call names
+func (r *router) Reload(ctx context.Context, v *pb.Void) (*pb.ReloadReply, error) {
+	return r.svr.cs.Reload(ctx, v)
+}
+
+func (r *router) Reindex(ctx context.Context, path *pb.Path) (*pb.ReindexReply, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.ReindexReply{}, err
+	}
+	return p.cs.Reindex(ctx, path)
+}
+
+func (r *router) Import(ctx context.Context, req *pb.ImportRequest) (*pb.ImportReply, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.ImportReply{}, err
+	}
+	return p.cs.Import(ctx, req)
+}
+
+func (r *router) Mark(ctx context.Context, b *pb.Bookmark) (*pb.Void, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.Void{}, err
+	}
+	return p.cs.Mark(ctx, b)
+}
+
+func (r *router) Unmark(ctx context.Context, b *pb.Bookmark) (*pb.Void, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.Void{}, err
+	}
+	return p.cs.Unmark(ctx, b)
+}
+
+func (r *router) Marks(ctx context.Context, v *pb.Void) (*pb.BookmarkList, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.BookmarkList{}, err
+	}
+	return p.cs.Marks(ctx, v)
+}
+
+func (r *router) Tag(ctx context.Context, req *pb.TagRequest) (*pb.Void, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.Void{}, err
+	}
+	return p.cs.Tag(ctx, req)
+}
+
+func (r *router) Untag(ctx context.Context, req *pb.TagRequest) (*pb.UntagReply, error) {
+	p, err := r.profile(ctx)
+	if err != nil {
+		return &pb.UntagReply{}, err
+	}
+	return p.cs.Untag(ctx, req)
+}
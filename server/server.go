@@ -1,268 +1,593 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/karrick/godirwalk"
 	log "github.com/sirupsen/logrus"
+	"github.com/walkert/ceedee/auth"
 	pb "github.com/walkert/ceedee/ceedeeproto"
-	"github.com/walkert/watcher"
+	"github.com/walkert/ceedee/index"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 var (
-	cdpath                 = regexp.MustCompile(`cd\s+([~\/]\/?.*[^\/]$)`)
 	defaultMonitorInterval = 10
 	defaultDirWalkInterval = 1
 )
 
-type directory struct {
-	path           string
-	histCandidates []candidate
-	pathCandidates []candidate
-	tracker        map[string]struct{}
+// buildIndex walks each root in the background and then starts watching
+// the history file. Queries are answered from whatever has been indexed so
+// far until it has finished, at which point ready is closed. Reloads wait
+// for it by holding reloadMux until the walks are done
+func (s *ceedeeServer) buildIndex(roots []string, opts index.WalkOptions, reloadMux *sync.Mutex) {
+	reloadMux.Lock()
+	atomic.StoreInt32(&s.building, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(s.ready)
+		var walks []index.WalkStats
+		for _, root := range roots {
+			stats, err := s.idx.AddRootContext(s.ctx, root, opts)
+			if err != nil {
+				log.Infof("Unable to walk %s: %v\n", root, err)
+				continue
+			}
+			walks = append(walks, stats)
+		}
+		reloadMux.Unlock()
+		if s.ctx.Err() != nil {
+			return
+		}
+		s.watchHistory()
+		atomic.StoreInt32(&s.building, 0)
+		s.recordWalks(walks)
+	}()
+}
+
+// indexing reports whether the index is still being built
+func (s *ceedeeServer) indexing() bool {
+	return atomic.LoadInt32(&s.building) == 1
+}
+
+// watchHistory reads the history file and then passes newly discovered
+// history entries to the index until the server's context is canceled
+func (s *ceedeeServer) watchHistory() {
+	log.Debugln("Launching history watcher for file", s.histFile)
+	s.readHistory()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.monitorInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				log.Debugln("Stopping history watcher for file", s.histFile)
+				return
+			case <-ticker.C:
+			}
+			s.readHistory()
+		}
+	}()
 }
 
-// addPathCandidate creates a new pathCandidates entry and sorts the list
-// according to the directory depth
-func (d *directory) addPathCandidate(path string) {
-	if _, ok := d.tracker[path]; ok {
+// readHistory passes any new lines in the history file to the index
+func (s *ceedeeServer) readHistory() {
+	s.histMux.Lock()
+	defer s.histMux.Unlock()
+	b, err := s.history.poll()
+	if err != nil {
+		log.Debugf("Unable to read history: %v\n", err)
 		return
 	}
-	log.Debugf("Adding a new candidate path %s to base %s\n", path, d.path)
-	d.tracker[path] = struct{}{}
-	c := candidate{path: path, depth: len(strings.Split(path, "/"))}
-	d.pathCandidates = append(d.pathCandidates, c)
-	sort.Slice(d.pathCandidates, func(i, j int) bool {
-		if d.pathCandidates[i].depth < d.pathCandidates[j].depth {
-			return true
+	if len(b) > 0 {
+		log.Debugf("Processing %d received bytes from history\n", len(b))
+		s.idx.IngestHistory(bytes.NewReader(b))
+	}
+}
+
+// backGroundDir walks the roots again every dirInterval hours until the
+// server's context is canceled
+func (s *ceedeeServer) backGroundDir() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.dirInterval) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			log.Debugln("Kicking off directory walk..")
+			stats, err := s.idx.RefreshContext(s.ctx)
+			if err != nil && s.ctx.Err() == nil {
+				log.Infof("Unable to walk the roots: %v\n", err)
+			}
+			s.recordWalks(stats)
 		}
-		return false
-	})
+	}()
 }
 
-// addHistCandidate createa a new histCandidates entry and sorts the list
-// by how many times the entry has appeared in the history
-func (d *directory) addHistCandidate(path string, count int) {
-	var exists bool
-	for idx, c := range d.histCandidates {
-		if c.path == path {
-			d.histCandidates[idx].count++
-			exists = true
+// recordWalks logs the outcome of directory walks, keeps the most recent
+// one of each root for status reporting and saves a snapshot of the index. A walk that
+// hit one of the configured limits is logged with the reason it was
+// truncated
+func (s *ceedeeServer) recordWalks(stats []index.WalkStats) {
+	for _, st := range stats {
+		if st.Truncated != "" {
+			log.Infof("Indexing of %s was truncated after %d directories: %s\n", st.Root, st.Dirs, st.Truncated)
+		}
+		if st.ErrorSkips > 0 {
+			log.Infof("Indexing of %s skipped %d directories which couldn't be read\n", st.Root, st.ErrorSkips)
 		}
+		log.Debugf("Indexing of %s took %s\n", st.Root, st.Duration)
+		s.mux.Lock()
+		s.walks[st.Root] = st
+		s.mux.Unlock()
 	}
-	if !exists {
-		c := candidate{path: path, count: count}
-		d.histCandidates = append(d.histCandidates, c)
+	s.saveSnapshot()
+}
+
+// saveSnapshot persists the index so that clients can still answer
+// queries while the server is down. The offset the history file has been
+// read up to is saved alongside it, in the snapshot file name with
+// ".offsets" appended, so that the two always agree
+func (s *ceedeeServer) saveSnapshot() {
+	if s.snapshotFile == "" {
+		return
 	}
-	sort.Slice(d.histCandidates, func(i, j int) bool {
-		if d.histCandidates[i].count > d.histCandidates[j].count {
-			return true
+	s.histMux.Lock()
+	defer s.histMux.Unlock()
+	if err := s.idx.SnapshotFile(s.snapshotFile); err != nil {
+		log.Infof("Unable to save a snapshot of the index: %v\n", err)
+		return
+	}
+	if s.history == nil {
+		return
+	}
+	offsets := map[string]int64{s.history.path: s.history.offset}
+	if err := writeOffsets(s.snapshotFile+".offsets", offsets); err != nil {
+		log.Infof("Unable to save the history offsets: %v\n", err)
+	}
+}
+
+// ceedeeServer represents a server object that implements the ceedeeproto
+// server interface
+type ceedeeServer struct {
+	blocklistFile   string
+	building        int32
+	cancel          context.CancelFunc
+	ctx             context.Context
+	dirInterval     int
+	histFile        string
+	histMux         sync.Mutex
+	history         *historyTailer
+	idx             *index.Index
+	missBudget      int64 // a time.Duration, accessed atomically
+	monitorInterval int
+	mux             sync.Mutex
+	ready           chan struct{}
+	reload          func() ([]string, error)
+	snapshotFile    string
+	started         time.Time
+	version         string
+	walks           map[string]index.WalkStats
+	wg              sync.WaitGroup
+}
+
+// forgetWalk drops the walk recorded for a root which is no longer indexed
+func (s *ceedeeServer) forgetWalk(root string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.walks, root)
+}
+
+// Get a path match (or not) from the index. Partial matches result in a colon-separarted list
+// being sent back while an explicit match returns a colon-separarted list of full paths.
+// While the index is still being built the reply is flagged as incomplete, as is the
+// NotFound error through its details
+func (s *ceedeeServer) Get(ctx context.Context, Directory *pb.Directory) (*pb.Dlist, error) {
+	incomplete := s.indexing()
+	results := s.idx.QueryFrom(Directory.Name, Directory.Cwd)
+	if len(results) == 0 {
+		results = s.searchMiss(ctx, Directory)
+	}
+	if len(results) == 0 {
+		st := status.New(codes.NotFound, fmt.Sprintf("No entry for directory %s", Directory.Name))
+		if incomplete {
+			if detailed, err := st.WithDetails(&pb.Dlist{Incomplete: true}); err == nil {
+				st = detailed
+			}
 		}
-		return false
-	})
+		return &pb.Dlist{}, st.Err()
+	}
+	return &pb.Dlist{Dirs: strings.Join(EncodeResults(results), ":"), Incomplete: incomplete}, nil
 }
 
-func (d *directory) candidateString() string {
-	var list []string
-	for _, h := range d.histCandidates {
-		list = append(list, fmt.Sprintf("e;%s", h.path))
+// searchMiss gives the index up to the miss budget to find directories
+// matching a name which it doesn't know about, searching around the
+// client's cwd and wherever has changed since the last walk
+func (s *ceedeeServer) searchMiss(ctx context.Context, Directory *pb.Directory) []index.Result {
+	budget := time.Duration(atomic.LoadInt64(&s.missBudget))
+	if budget <= 0 || Directory.Name == "" {
+		return nil
 	}
-	for _, p := range d.pathCandidates {
-		list = append(list, fmt.Sprintf("e;%s", p.path))
+	if _, tags := index.ParseQuery(Directory.Name); len(tags) > 0 {
+		// Tags are only ever added by hand, so searching the filesystem
+		// can't find any more tagged paths
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	start := time.Now()
+	added := s.idx.Probe(ctx, Directory.Name, Directory.Cwd)
+	log.Debugf("Searching for %s added %d directories in %s\n", Directory.Name, added, time.Since(start))
+	if added == 0 {
+		return nil
 	}
-	return strings.Join(list, ":")
+	return s.idx.QueryFrom(Directory.Name, Directory.Cwd)
 }
 
-type candidate struct {
-	count int
-	depth int
-	path  string
+// EncodeResults converts query results into the strings sent to clients.
+// Exact matches are prefixed with "e;", partial matches with "p;" and
+// bookmarks with "b;"
+func EncodeResults(results []index.Result) []string {
+	encoded := make([]string, len(results))
+	for n, r := range results {
+		prefix := "e"
+		switch r.Match {
+		case index.Partial:
+			prefix = "p"
+		case index.Bookmarked:
+			prefix = "b"
+		}
+		encoded[n] = fmt.Sprintf("%s;%s", prefix, r.Path)
+	}
+	return encoded
 }
 
-// processBytes iterates over new data from the history file to determine
-// if any new history candidates should be created.
-func (s *ceedeeServer) processBytes(b []byte) {
-	if len(b) == 0 {
-		return
+// Status reports the server's version, pid and start time, the size of the
+// index, the progress of any running walks and the outcome of the last walk
+// of each root, how much of the history file has been read and the memory
+// in use
+func (s *ceedeeServer) Status(ctx context.Context, v *pb.Void) (*pb.ServerStatus, error) {
+	stats := s.idx.Stats()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	st := &pb.ServerStatus{
+		Version:        s.version,
+		Pid:            int32(os.Getpid()),
+		StartTime:      s.started.Unix(),
+		Indexing:       s.indexing(),
+		Names:          int32(stats.Names),
+		Paths:          int32(stats.Paths),
+		HistoryEntries: int32(stats.History),
+		HeapBytes:      mem.HeapAlloc,
+		SysBytes:       mem.Sys,
+	}
+	for _, w := range s.idx.Walking() {
+		st.Walks = append(st.Walks, &pb.WalkProgress{
+			Root:      w.Root,
+			StartTime: w.Start.Unix(),
+			Dirs:      int32(w.Dirs),
+		})
 	}
 	s.mux.Lock()
-	defer s.mux.Unlock()
-	var command string
-	pathMap := make(map[string]int)
-	for _, line := range strings.Split(string(b), "\n") {
-		parts := strings.Split(line, ";")
-		if len(parts) == 1 {
-			command = parts[0]
-		} else {
-			command = parts[len(parts)-1]
-		}
-		match := cdpath.FindStringSubmatch(command)
-		if len(match) != 2 {
+	for _, w := range s.walks {
+		st.LastWalks = append(st.LastWalks, walkSummary(w))
+	}
+	s.mux.Unlock()
+	sort.Slice(st.LastWalks, func(i, j int) bool {
+		return st.LastWalks[i].Root < st.LastWalks[j].Root
+	})
+	if s.history != nil {
+		s.histMux.Lock()
+		hf := &pb.HistoryFile{
+			Path:          s.history.path,
+			Offset:        s.history.offset,
+			Lines:         int32(stats.HistoryLines),
+			ParseFailures: int32(stats.HistoryFailures),
+		}
+		s.histMux.Unlock()
+		if fi, err := os.Stat(hf.Path); err == nil {
+			hf.Size = fi.Size()
+		}
+		st.HistoryFiles = append(st.HistoryFiles, hf)
+	}
+	return st, nil
+}
+
+// walkSummary converts the stats of a completed walk for the Status reply
+func walkSummary(w index.WalkStats) *pb.WalkSummary {
+	summary := &pb.WalkSummary{
+		Root:       w.Root,
+		StartTime:  w.Start.Unix(),
+		DurationMs: int64(w.Duration / time.Millisecond),
+		Dirs:       int32(w.Dirs),
+		ErrorSkips: int32(w.ErrorSkips),
+		Truncated:  w.Truncated,
+	}
+	for rule, count := range w.SkipHits {
+		summary.SkipHits = append(summary.SkipHits, &pb.SkipHit{Rule: rule, Count: int32(count)})
+	}
+	sort.Slice(summary.SkipHits, func(i, j int) bool {
+		return summary.SkipHits[i].Rule < summary.SkipHits[j].Rule
+	})
+	return summary
+}
+
+// Visit records a visit to a directory, raising its rank
+func (s *ceedeeServer) Visit(ctx context.Context, p *pb.Path) (*pb.Void, error) {
+	if p.Path == "" {
+		return &pb.Void{}, status.Error(codes.InvalidArgument, "no path supplied")
+	}
+	s.idx.Visit(p.Path)
+	return &pb.Void{}, nil
+}
+
+// List streams every indexed path to the client, along with where its rank
+// comes from and its score
+func (s *ceedeeServer) List(req *pb.ListRequest, stream pb.CeeDee_ListServer) error {
+	for _, e := range s.idx.Entries() {
+		if req.Tag != "" && !hasTag(e.Tags, req.Tag) {
 			continue
 		}
-		path := match[1]
-		if strings.HasPrefix(path, "~") {
-			path = strings.Replace(path, "~", s.home, 1)
+		entry := &pb.Entry{
+			Path:    e.Path,
+			Source:  e.Source,
+			Count:   int32(e.Count),
+			Visits:  int32(e.Visits),
+			Depth:   int32(e.Depth),
+			Score:   e.Score,
+			Tags:    e.Tags,
+			Project: e.Project,
+		}
+		if !e.LastVisit.IsZero() {
+			entry.LastVisit = e.LastVisit.Unix()
 		}
-		if _, ok := pathMap[path]; ok {
-			pathMap[path]++
-		} else {
-			pathMap[path] = 1
+		if err := stream.Send(entry); err != nil {
+			return err
 		}
 	}
-	// Now that we have our paths with the counts, see if they're already in
-	// the directory map and if they are, ensure that they're first in the list of options
-	// if appropriate
-	for path, count := range pathMap {
-		base := filepath.Base(path)
-		_, ok := s.dirData[base]
-		if !ok {
-			// TODO: Run addHistCandidate here to create a new entry even if it hasn't been
-			//		 seen by the filepath walker.
-			continue
+	return nil
+}
+
+// Forget removes a path, or every path matching a pattern, from the index
+// along with its history. When asked to, the path or pattern is also added
+// to the blocklist, which removes everything below it as well and stops it
+// from being indexed again
+func (s *ceedeeServer) Forget(ctx context.Context, req *pb.ForgetRequest) (*pb.ForgetReply, error) {
+	if req.Path == "" {
+		return &pb.ForgetReply{}, status.Error(codes.InvalidArgument, "no path supplied")
+	}
+	var removed int
+	var err error
+	switch {
+	case req.Block:
+		removed, err = s.block(req.Path)
+	case !strings.ContainsAny(req.Path, "*?["):
+		removed = s.idx.Forget(req.Path)
+	default:
+		removed, err = s.idx.ForgetMatching(req.Path)
+		if err != nil {
+			err = status.Error(codes.InvalidArgument, err.Error())
 		}
-		log.Debugf("Adding/updating a hist path link %s->%s\n", base, path)
-		s.dirData[base].addHistCandidate(path, count)
 	}
+	if err != nil {
+		return &pb.ForgetReply{}, err
+	}
+	if removed > 0 {
+		s.saveSnapshot()
+	}
+	return &pb.ForgetReply{Removed: int32(removed)}, nil
 }
 
-// watchHistory passes newly discovered history entres to processBytes
-func (s *ceedeeServer) watchHistory() error {
-	w, err := watcher.New(s.histFile, watcher.WithChannelMonitor(s.monitorInterval))
+// block adds pattern to the end of the blocklist file and applies the
+// updated blocklist
+func (s *ceedeeServer) block(pattern string) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.blocklistFile == "" {
+		return 0, status.Error(codes.FailedPrecondition, "the server has no blocklist file")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid pattern %q: %v", pattern, err)
+	}
+	// Start from the file rather than the index so that any edits made to
+	// it since it was loaded are kept
+	patterns, err := readBlocklist(s.blocklistFile)
 	if err != nil {
-		return err
+		return 0, status.Errorf(codes.Internal, "unable to read the blocklist: %v", err)
 	}
-	log.Debugln("Launching history watcher for file", s.histFile)
-	go func() {
-		for {
-			select {
-			case bytes := <-w.ByteChannel:
-				log.Debugf("Processing %d received bytes from history\n", len(bytes))
-				s.processBytes(bytes)
-			case err := <-w.ErrChannel:
-				log.Debugf("Received error from watcher: %v\n", err)
-				return
-			}
+	for _, existing := range patterns {
+		if existing == pattern {
+			return 0, nil
 		}
-	}()
-	return nil
+	}
+	patterns = append(patterns, pattern)
+	if err := appendBlocklist(s.blocklistFile, pattern); err != nil {
+		return 0, status.Errorf(codes.Internal, "unable to save the blocklist: %v", err)
+	}
+	removed, err := s.idx.SetBlocklist(patterns)
+	if err != nil {
+		return 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Infof("Blocked %s, removing %d paths\n", pattern, removed)
+	return removed, nil
 }
 
-// walker is the func passed to filepath.Walk for creating new pathCandidates
-func (s *ceedeeServer) walker(path string, de *godirwalk.Dirent) error {
-	if !de.IsDir() {
-		return nil
+// Reload re-reads the server's configuration and applies any changes
+func (s *ceedeeServer) Reload(ctx context.Context, v *pb.Void) (*pb.ReloadReply, error) {
+	changes, err := s.reload()
+	if err != nil {
+		return &pb.ReloadReply{}, status.Error(codes.FailedPrecondition, err.Error())
 	}
-	base := filepath.Base(path)
-	_, baseMatch := s.skipList[base]
-	_, fullMatch := s.skipList[path]
-	if baseMatch || fullMatch {
-		log.Debugln("Skipping", path)
-		return filepath.SkipDir
-	}
-	_, ok := s.dirData[base]
-	if !ok {
-		log.Debugln("Creating new directory reference for", base)
-		d := &directory{path: base, tracker: make(map[string]struct{})}
-		d.addPathCandidate(path)
-		s.dirData[base] = d
+	return &pb.ReloadReply{Changes: changes}, nil
+}
+
+// Reindex walks a directory, or every root when no path is given, straight
+// away rather than waiting for the next background walk. The walk stops if
+// the client goes away
+func (s *ceedeeServer) Reindex(ctx context.Context, p *pb.Path) (*pb.ReindexReply, error) {
+	stats, err := s.idx.Reindex(ctx, p.Path)
+	if err == index.ErrNoRoot {
+		return &pb.ReindexReply{}, status.Errorf(codes.InvalidArgument, "%s is not below any root", p.Path)
+	}
+	if err != nil {
+		return &pb.ReindexReply{}, status.Errorf(codes.Internal, "unable to reindex: %v", err)
+	}
+	if p.Path == "" {
+		s.recordWalks(stats.Walks)
 	} else {
-		s.dirData[base].addPathCandidate(path)
+		s.saveSnapshot()
 	}
-	return nil
+	log.Infof("Reindex of %s added %d and removed %d directories\n", reindexTarget(p.Path), stats.Added, stats.Removed)
+	return &pb.ReindexReply{
+		Added:     int32(stats.Added),
+		Removed:   int32(stats.Removed),
+		Truncated: stats.Truncated,
+	}, nil
 }
 
-func (s *ceedeeServer) backGroundDir() {
-	go func() {
-		for range time.Tick(time.Duration(s.dirInterval) * time.Hour) {
-			log.Debugln("Kicking off directory walk..")
-			s.buildDirStructure()
+// Import merges the ranks of paths from another directory jumper's
+// database into the history, skipping any which no longer exist
+func (s *ceedeeServer) Import(ctx context.Context, req *pb.ImportRequest) (*pb.ImportReply, error) {
+	reply := &pb.ImportReply{}
+	var entries []index.Imported
+	for _, e := range req.Entries {
+		if fi, err := os.Stat(e.Path); err != nil || !fi.IsDir() {
+			reply.Missing++
+			continue
 		}
-	}()
+		imported := index.Imported{Path: e.Path, Rank: e.Rank}
+		if e.LastVisit > 0 {
+			imported.LastVisit = time.Unix(e.LastVisit, 0)
+		}
+		entries = append(entries, imported)
+	}
+	reply.Imported = int32(s.idx.Import(entries))
+	log.Infof("Imported %d paths, skipping %d which no longer exist\n", reply.Imported, reply.Missing)
+	if reply.Imported > 0 {
+		s.saveSnapshot()
+	}
+	return reply, nil
 }
 
-// buildDirStructure finds directories in s.root and adds them to the
-// pathCandidates list
-func (s *ceedeeServer) buildDirStructure() error {
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	start := time.Now()
-	err := godirwalk.Walk(s.root, &godirwalk.Options{
-		Callback: s.walker,
-		ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
-			return godirwalk.SkipNode
-		},
-		Unsorted: true,
-	})
-	if err != nil {
-		return err
+// Mark bookmarks a path under a short name, which queries for the name
+// then return first
+func (s *ceedeeServer) Mark(ctx context.Context, b *pb.Bookmark) (*pb.Void, error) {
+	if err := s.idx.Mark(b.Name, b.Path); err != nil {
+		return &pb.Void{}, status.Error(codes.InvalidArgument, err.Error())
 	}
-	delta := time.Now().Sub(start)
-	log.Debugf("Indexing of %s took %s\n", s.root, delta)
-	return nil
+	s.saveSnapshot()
+	return &pb.Void{}, nil
 }
 
-// ceedeeServer represents a server object that implements the ceedeeproto
-// server interface
-type ceedeeServer struct {
-	dirData         map[string]*directory
-	dirInterval     int
-	histFile        string
-	home            string
-	monitorInterval int
-	mux             sync.Mutex
-	root            string
-	skipList        map[string]int
+// Unmark removes a bookmark
+func (s *ceedeeServer) Unmark(ctx context.Context, b *pb.Bookmark) (*pb.Void, error) {
+	if !s.idx.Unmark(b.Name) {
+		return &pb.Void{}, status.Errorf(codes.NotFound, "no bookmark named %s", b.Name)
+	}
+	s.saveSnapshot()
+	return &pb.Void{}, nil
 }
 
-func (s *ceedeeServer) getPartial(name string) []string {
-	start := time.Now()
-	var matches []string
-	for path := range s.dirData {
-		if strings.Index(path, name) > -1 {
-			log.Debugln("Found a match for name:", name)
-			matches = append(matches, fmt.Sprintf("p;%s", path))
-		}
+// Marks returns every bookmark, sorted by name
+func (s *ceedeeServer) Marks(ctx context.Context, v *pb.Void) (*pb.BookmarkList, error) {
+	list := &pb.BookmarkList{}
+	for _, m := range s.idx.Marks() {
+		list.Bookmarks = append(list.Bookmarks, &pb.Bookmark{Name: m.Name, Path: m.Path})
 	}
-	sort.Strings(matches)
-	log.Debugln("Time taken to find partial:", time.Now().Sub(start))
-	return matches
+	return list, nil
 }
 
-// Get a path match (or not) from dirData. Partial matches result in a colon-separarted list
-// being sent back while an explicit match returns a colon-separarted list of full paths
-func (s *ceedeeServer) Get(ctx context.Context, Directory *pb.Directory) (*pb.Dlist, error) {
-	dir, ok := s.dirData[Directory.Name]
-	if !ok {
-		log.Debugf("No direct match for %s, starting partial check..\n", Directory.Name)
-		results := s.getPartial(Directory.Name)
-		if len(results) == 0 {
-			return &pb.Dlist{}, fmt.Errorf("No entry for directory %s", Directory.Name)
+// hasTag reports whether tag, with or without a leading '@', is in tags
+func hasTag(tags []string, tag string) bool {
+	tag = strings.TrimPrefix(tag, "@")
+	for _, t := range tags {
+		if t == tag {
+			return true
 		}
-		return &pb.Dlist{Dirs: strings.Join(results, ":")}, nil
 	}
-	return &pb.Dlist{Dirs: dir.candidateString()}, nil
+	return false
+}
+
+// Tag adds tags to a path
+func (s *ceedeeServer) Tag(ctx context.Context, req *pb.TagRequest) (*pb.Void, error) {
+	if err := s.idx.Tag(req.Path, req.Tags...); err != nil {
+		return &pb.Void{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.saveSnapshot()
+	return &pb.Void{}, nil
+}
+
+// Untag removes tags from a path, or every tag it has if none are given
+func (s *ceedeeServer) Untag(ctx context.Context, req *pb.TagRequest) (*pb.UntagReply, error) {
+	removed := s.idx.Untag(req.Path, req.Tags...)
+	if removed == 0 && len(req.Tags) == 0 {
+		return &pb.UntagReply{}, status.Errorf(codes.NotFound, "%s has no tags", req.Path)
+	}
+	if removed == 0 {
+		return &pb.UntagReply{}, status.Errorf(codes.NotFound, "%s has none of those tags", req.Path)
+	}
+	s.saveSnapshot()
+	return &pb.UntagReply{Removed: int32(removed)}, nil
+}
+
+// reindexTarget describes what a reindex of path covers for log messages
+func reindexTarget(path string) string {
+	if path == "" {
+		return "every root"
+	}
+	return path
 }
 
 // Server is an exported struct which represents the grpc server process and takes various
 // options
 type Server struct {
+	name            string
+	blocklistFile   string
 	histFile        string
 	home            string
 	root            string
+	roots           []string
 	monitorInterval int
 	dirInterval     int
+	missBudget      time.Duration
 	port            int
-	skipList        map[string]int
+	snapshotFile    string
+	socket          string
+	tlsCert         string
+	tlsKey          string
+	tokenFile       string
+	version         string
+	walkOpts        index.WalkOptions
+	weights         index.Weights
+	reloadFunc      func() ([]Opt, error)
+	reloadMux       sync.Mutex
+	profileOpts     []profileConfig
+	profiles        map[string]*Server
+	ready           chan struct{}
+	cs              *ceedeeServer
 	l               net.Listener
 	s               *grpc.Server
 }
@@ -272,6 +597,186 @@ type Opt func(s *Server)
 
 // New returns a configured Server object which runs the grpc server
 func New(opts ...Opt) (*Server, error) {
+	svr := configure(opts)
+	svr.name = DefaultProfile
+	svr.profiles = make(map[string]*Server)
+	for _, pc := range svr.profileOpts {
+		if _, ok := svr.profiles[pc.name]; ok || pc.name == "" || pc.name == DefaultProfile {
+			return nil, fmt.Errorf("invalid or duplicate profile name '%s'", pc.name)
+		}
+		p := configure(pc.opts)
+		p.name = pc.name
+		svr.profiles[pc.name] = p
+	}
+	snapshots := make(map[string]string)
+	for _, p := range svr.all() {
+		if other, ok := snapshots[p.snapshotFile]; ok && p.snapshotFile != "" {
+			return nil, fmt.Errorf("profiles %s and %s both use the snapshot file %s", other, p.name, p.snapshotFile)
+		}
+		snapshots[p.snapshotFile] = p.name
+	}
+	lis, err := svr.listen()
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %v", err)
+	}
+	serverOpts, err := svr.serverOptions(lis)
+	if err != nil {
+		lis.Close()
+		return nil, err
+	}
+	for _, p := range svr.all() {
+		if p.cs, err = p.newProfile(); err != nil {
+			lis.Close()
+			if p != svr {
+				err = fmt.Errorf("profile %s: %v", p.name, err)
+			}
+			return nil, err
+		}
+		p.cs.reload = svr.Reload
+	}
+	svr.ready = make(chan struct{})
+	for _, p := range svr.all() {
+		p.cs.buildIndex(p.roots, p.walkOpts, &p.reloadMux)
+		p.cs.backGroundDir()
+	}
+	go func() {
+		for _, p := range svr.all() {
+			<-p.cs.ready
+		}
+		close(svr.ready)
+	}()
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterCeeDeeServer(s, &router{svr: svr})
+	svr.s = s
+	svr.l = lis
+	return svr, nil
+}
+
+// newProfile checks the roots and history file of a profile and returns
+// the ceedeeServer which serves it, with its index restored from the
+// snapshot and the blocklist applied
+func (svr *Server) newProfile() (*ceedeeServer, error) {
+	for _, root := range svr.roots {
+		if _, err := os.Stat(root); err != nil {
+			return nil, err
+		}
+	}
+	history, err := newHistoryTailer(svr.histFile)
+	if err != nil {
+		return nil, err
+	}
+	idx, restored := svr.restore()
+	if restored {
+		svr.resumeHistory(history)
+	}
+	blocklist, err := readBlocklist(svr.blocklistFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the blocklist: %v", err)
+	}
+	if _, err := idx.SetBlocklist(blocklist); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ceedeeServer{
+		blocklistFile:   svr.blocklistFile,
+		cancel:          cancel,
+		ctx:             ctx,
+		dirInterval:     svr.dirInterval,
+		histFile:        svr.histFile,
+		history:         history,
+		idx:             idx,
+		missBudget:      int64(svr.missBudget),
+		monitorInterval: svr.monitorInterval,
+		ready:           make(chan struct{}),
+		walks:           make(map[string]index.WalkStats),
+		snapshotFile:    svr.snapshotFile,
+		started:         time.Now(),
+		version:         svr.version,
+	}, nil
+}
+
+// restore returns the index saved in the snapshot file, or an empty index
+// if there isn't one. The bool reports whether the snapshot was used
+func (svr *Server) restore() (*index.Index, bool) {
+	opts := []index.Opt{index.WithHome(svr.home), index.WithWeights(svr.weights)}
+	if svr.snapshotFile != "" {
+		idx, err := index.RestoreFile(svr.snapshotFile, opts...)
+		if err == nil {
+			log.Infof("Restored %d directory names from %s\n", idx.Len(), svr.snapshotFile)
+			return idx, true
+		}
+		if !os.IsNotExist(err) {
+			log.Infof("Unable to restore the snapshot: %v\n", err)
+		}
+	}
+	return index.New(opts...), false
+}
+
+// resumeHistory moves history on to the offset saved with the snapshot, so
+// that history which is already in the restored index isn't counted again.
+// Without a usable offset the history up to now is skipped
+func (svr *Server) resumeHistory(history *historyTailer) {
+	size := int64(0)
+	if fi, err := os.Stat(history.path); err == nil {
+		size = fi.Size()
+	}
+	offsets, err := readOffsets(svr.snapshotFile + ".offsets")
+	offset, ok := offsets[history.path]
+	if err != nil || !ok || offset > size {
+		log.Infof("No usable history offset for %s, only reading new history\n", history.path)
+		offset = size
+	}
+	history.offset = offset
+}
+
+// listen returns the listener given with WithListener or, failing that,
+// listens on the unix domain socket or TCP port. A unix domain socket
+// listener only accepts connections from the current user
+func (svr *Server) listen() (net.Listener, error) {
+	switch {
+	case svr.l != nil && svr.l.Addr().Network() == "unix":
+		return &peerCredListener{Listener: svr.l, uid: os.Getuid()}, nil
+	case svr.l != nil:
+		return svr.l, nil
+	case svr.socket != "":
+		return listenUnix(svr.socket)
+	default:
+		return net.Listen("tcp", fmt.Sprintf("localhost:%d", svr.port))
+	}
+}
+
+// serverOptions returns the grpc options which require clients connecting
+// to lis over TCP to present the token and use TLS, when configured
+func (svr *Server) serverOptions(lis net.Listener) ([]grpc.ServerOption, error) {
+	var serverOpts []grpc.ServerOption
+	if lis.Addr().Network() == "unix" {
+		return serverOpts, nil
+	}
+	if svr.tokenFile != "" {
+		token, err := auth.EnsureToken(svr.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read token: %v", err)
+		}
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(tokenInterceptor(token)),
+			grpc.StreamInterceptor(tokenStreamInterceptor(token)),
+		)
+	}
+	if svr.tlsCert != "" {
+		if err := ensureCert(svr.tlsCert, svr.tlsKey); err != nil {
+			return nil, fmt.Errorf("unable to create certificate: %v", err)
+		}
+		creds, err := credentials.NewServerTLSFromFile(svr.tlsCert, svr.tlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load certificate: %v", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	return serverOpts, nil
+}
+
+// configure returns a Server with opts and the defaults applied
+func configure(opts []Opt) *Server {
 	svr := &Server{}
 	for _, opt := range opts {
 		opt(svr)
@@ -282,48 +787,180 @@ func New(opts ...Opt) (*Server, error) {
 	if svr.dirInterval == 0 {
 		svr.dirInterval = defaultDirWalkInterval
 	}
-	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", svr.port))
-	if err != nil {
-		return nil, fmt.Errorf("failed to listen: %v", err)
+	if svr.weights == (index.Weights{}) {
+		svr.weights = index.DefaultWeights
 	}
-	s := grpc.NewServer()
-	dirData := make(map[string]*directory)
-	cServer := &ceedeeServer{
-		dirData:         dirData,
-		dirInterval:     svr.dirInterval,
-		histFile:        svr.histFile,
-		home:            svr.home,
-		monitorInterval: svr.monitorInterval,
-		mux:             sync.Mutex{},
-		root:            svr.root,
+	if svr.root != "" {
+		svr.roots = append([]string{svr.root}, svr.roots...)
 	}
-	if svr.skipList != nil {
-		cServer.skipList = svr.skipList
+	return svr
+}
+
+// Reload fetches a fresh set of options from the func given to
+// WithReloadFunc and applies any changes to the roots, the walk options,
+// the ranking weights, the miss budget and the blocklist of every profile.
+// Only roots which were added, or whose walk options changed, are walked;
+// the rest of the index, including history ranks and visits, is kept.
+// Changes to any other options, and adding or removing profiles, need a
+// restart. Reload returns a description of each change it applied. A
+// profile which can't be reloaded doesn't stop the others, but its error is
+// returned
+func (s *Server) Reload() ([]string, error) {
+	s.reloadMux.Lock()
+	defer s.reloadMux.Unlock()
+	if s.reloadFunc == nil {
+		return nil, fmt.Errorf("reloading is not enabled")
 	}
-	err = cServer.buildDirStructure()
+	opts, err := s.reloadFunc()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to reload the configuration: %v", err)
 	}
-	cServer.backGroundDir()
-	err = cServer.watchHistory()
+	next := configure(opts)
+	var failed []string
+	changes, err := s.reloadProfile(next)
 	if err != nil {
-		return nil, err
+		failed = append(failed, err.Error())
 	}
-	pb.RegisterCeeDeeServer(s, cServer)
-	svr.s = s
-	svr.l = lis
-	return svr, nil
+	wanted := make(map[string]bool)
+	for _, pc := range next.profileOpts {
+		wanted[pc.name] = true
+		p, ok := s.profiles[pc.name]
+		if !ok {
+			log.Infof("Reload: profile %s needs a restart to be served\n", pc.name)
+			continue
+		}
+		p.reloadMux.Lock()
+		profileChanges, err := p.reloadProfile(configure(pc.opts))
+		p.reloadMux.Unlock()
+		for _, change := range profileChanges {
+			changes = append(changes, fmt.Sprintf("profile %s: %s", pc.name, change))
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("profile %s: %v", pc.name, err))
+		}
+	}
+	for name := range s.profiles {
+		if !wanted[name] {
+			log.Infof("Reload: profile %s is still served until a restart\n", name)
+		}
+	}
+	if len(failed) > 0 {
+		return changes, errors.New(strings.Join(failed, "; "))
+	}
+	return changes, nil
+}
+
+// reloadProfile applies the changes in next to the profile served by s.
+// The caller must hold s.reloadMux
+func (s *Server) reloadProfile(next *Server) ([]string, error) {
+	var changes []string
+	if next.weights != s.weights {
+		s.cs.idx.SetWeights(next.weights)
+		s.weights = next.weights
+		changes = append(changes, fmt.Sprintf("ranking weights set to %+v", next.weights))
+	}
+	if next.missBudget != s.missBudget {
+		atomic.StoreInt64(&s.cs.missBudget, int64(next.missBudget))
+		s.missBudget = next.missBudget
+		changes = append(changes, fmt.Sprintf("miss budget set to %s", next.missBudget))
+	}
+	blocklist, err := readBlocklist(next.blocklistFile)
+	if err != nil {
+		return changes, fmt.Errorf("unable to read the blocklist: %v", err)
+	}
+	s.cs.mux.Lock()
+	s.cs.blocklistFile = next.blocklistFile
+	s.cs.mux.Unlock()
+	if !reflect.DeepEqual(blocklist, s.cs.idx.Blocklist()) {
+		removed, err := s.cs.idx.SetBlocklist(blocklist)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("blocklist set to %d patterns, removing %d paths", len(blocklist), removed))
+	}
+	walkChanged := !reflect.DeepEqual(next.walkOpts, s.walkOpts)
+	wanted := make(map[string]bool)
+	for _, root := range next.roots {
+		wanted[root] = true
+	}
+	for _, root := range s.roots {
+		if !wanted[root] {
+			s.cs.idx.RemoveRoot(root)
+			s.cs.forgetWalk(root)
+			changes = append(changes, fmt.Sprintf("removed root %s", root))
+		}
+	}
+	current := make(map[string]bool)
+	for _, root := range s.roots {
+		current[root] = true
+	}
+	// A root whose walk options changed is walked again in place, so its
+	// directories can still be found meanwhile and those which are no
+	// longer indexed are pruned once the walk completes. A root which
+	// can't be walked is left as it was and the rest carry on
+	var walks []index.WalkStats
+	var failed []string
+	rescanFailed := false
+	for _, root := range next.roots {
+		change := fmt.Sprintf("added root %s", root)
+		if current[root] {
+			if !walkChanged {
+				continue
+			}
+			change = fmt.Sprintf("rescanned root %s", root)
+		}
+		stats, err := s.cs.idx.AddRootContext(s.cs.ctx, root, next.walkOpts)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", root, err))
+			rescanFailed = rescanFailed || current[root]
+			continue
+		}
+		walks = append(walks, stats)
+		changes = append(changes, change)
+	}
+	// Only the roots the index holds are recorded, so that a root which
+	// failed to be added is tried again by the next reload, as is every
+	// root if one of them couldn't be walked with the new options
+	s.roots = s.cs.idx.Roots()
+	if !rescanFailed {
+		s.walkOpts = next.walkOpts
+	}
+	if len(changes) > 0 {
+		s.cs.recordWalks(walks)
+	}
+	for _, change := range changes {
+		if s.name != DefaultProfile {
+			change = fmt.Sprintf("profile %s: %s", s.name, change)
+		}
+		log.Infoln("Reload:", change)
+	}
+	if len(failed) > 0 {
+		return changes, fmt.Errorf("unable to walk %s", strings.Join(failed, "; "))
+	}
+	return changes, nil
 }
 
 // WithSkipList accepts directories which should be skipped during the
 // directory walk
 func WithSkipList(dirs []string) Opt {
-	var skips = make(map[string]int)
-	for _, dir := range dirs {
-		skips[dir] = 1
+	return func(s *Server) {
+		s.walkOpts.Skip = dirs
+	}
+}
+
+// WithIgnore accepts glob patterns for directories which should be skipped
+// during the directory walk. Patterns are matched against both the
+// basename and the full path
+func WithIgnore(patterns []string) Opt {
+	return func(s *Server) {
+		s.walkOpts.Ignore = patterns
 	}
+}
+
+// WithWeights sets the weights used to rank directories with the same name
+func WithWeights(w index.Weights) Opt {
 	return func(s *Server) {
-		s.skipList = skips
+		s.weights = w
 	}
 }
 
@@ -334,13 +971,51 @@ func WithHistFile(name string) Opt {
 	}
 }
 
-// WithPort sets the port the grpc server will listen on
+// WithPort sets the port the grpc server will listen on. Port 0 picks a
+// free port, which Addr reports
 func WithPort(port int) Opt {
 	return func(s *Server) {
 		s.port = port
 	}
 }
 
+// WithSocket makes the grpc server listen on a unix domain socket at path
+// instead of a TCP port. The socket is only accessible by the current user
+func WithSocket(path string) Opt {
+	return func(s *Server) {
+		s.socket = path
+	}
+}
+
+// WithListener makes the grpc server accept connections from l, e.g. one
+// inherited through socket activation, rather than listening itself. The
+// server takes ownership of l. The token and TLS options apply when l is
+// not a unix domain socket
+func WithListener(l net.Listener) Opt {
+	return func(s *Server) {
+		s.l = l
+	}
+}
+
+// WithTokenFile requires TCP clients to present the shared-secret token
+// stored in path. The file is created with a random token if it does not
+// exist
+func WithTokenFile(path string) Opt {
+	return func(s *Server) {
+		s.tokenFile = path
+	}
+}
+
+// WithTLS serves TCP clients over TLS using the certificate and key in
+// certFile and keyFile. A self-signed certificate for localhost is generated
+// if they do not exist
+func WithTLS(certFile, keyFile string) Opt {
+	return func(s *Server) {
+		s.tlsCert = certFile
+		s.tlsKey = keyFile
+	}
+}
+
 // WithRoot sets the root directory that the directory walk
 // will operate on
 func WithRoot(root string) Opt {
@@ -349,6 +1024,14 @@ func WithRoot(root string) Opt {
 	}
 }
 
+// WithRoots adds further root directories to walk alongside the one set
+// by WithRoot
+func WithRoots(roots []string) Opt {
+	return func(s *Server) {
+		s.roots = append(s.roots, roots...)
+	}
+}
+
 // WithHome sets the home directory to use for '~' substitutions
 func WithHome(home string) Opt {
 	return func(s *Server) {
@@ -370,16 +1053,168 @@ func WithDirInterval(interval int) Opt {
 	}
 }
 
+// WithBlocklist keeps the patterns of paths which are never indexed in the
+// file at path, one per line. Forget adds to it when asked to block a path
+func WithBlocklist(path string) Opt {
+	return func(s *Server) {
+		s.blocklistFile = path
+	}
+}
+
+// WithMissBudget lets a query for a name which isn't in the index spend up
+// to budget searching for it around the client's working directory and
+// below directories which have changed since the last walk. A budget of 0,
+// the default, turns the search off
+func WithMissBudget(budget time.Duration) Opt {
+	return func(s *Server) {
+		s.missBudget = budget
+	}
+}
+
+// WithProjectMarkers sets the names of files or directories, such as
+// go.mod, which mark the directory holding them as a project root. Project
+// roots rank higher and can be searched for on their own
+func WithProjectMarkers(markers []string) Opt {
+	return func(s *Server) {
+		s.walkOpts.Markers = markers
+	}
+}
+
+// WithMaxDepth limits how many levels below the root the directory walk
+// will descend. A depth of 0 means no limit
+func WithMaxDepth(depth int) Opt {
+	return func(s *Server) {
+		s.walkOpts.MaxDepth = depth
+	}
+}
+
+// WithMaxDirs caps the total number of directories indexed by a single
+// walk. A value of 0 means no limit
+func WithMaxDirs(max int) Opt {
+	return func(s *Server) {
+		s.walkOpts.MaxDirs = max
+	}
+}
+
+// WithWalkTimeout sets a time budget for each directory walk. A walk
+// which exceeds the budget is stopped and the partial results are kept
+func WithWalkTimeout(timeout time.Duration) Opt {
+	return func(s *Server) {
+		s.walkOpts.Timeout = timeout
+	}
+}
+
+// WithOneFileSystem prevents the directory walk from crossing onto
+// filesystems other than the one the root lives on
+func WithOneFileSystem(enabled bool) Opt {
+	return func(s *Server) {
+		s.walkOpts.OneFileSystem = enabled
+	}
+}
+
+// WithFollowSymlinks makes the directory walk descend into symlinked
+// directories. Directories reachable through more than one path are only
+// indexed once and symlink cycles are not followed
+func WithFollowSymlinks(enabled bool) Opt {
+	return func(s *Server) {
+		s.walkOpts.FollowSymlinks = enabled
+	}
+}
+
+// WithCanonicalPaths stores indexed directories with all symlinks resolved
+// rather than as they were visited during the walk
+func WithCanonicalPaths(enabled bool) Opt {
+	return func(s *Server) {
+		s.walkOpts.CanonicalPaths = enabled
+	}
+}
+
+// WithSnapshot saves a snapshot of the index to path after every
+// directory walk
+func WithSnapshot(path string) Opt {
+	return func(s *Server) {
+		s.snapshotFile = path
+	}
+}
+
+// WithVersion sets the version reported by the Status call
+func WithVersion(version string) Opt {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithReloadFunc enables reloading. fn is called by Reload to fetch a
+// fresh set of options, typically by reading the configuration again
+func WithReloadFunc(fn func() ([]Opt, error)) Opt {
+	return func(s *Server) {
+		s.reloadFunc = fn
+	}
+}
+
+// Ready returns a channel which is closed once the first walk of every root
+// of every profile has finished and the history file has been read. Until then queries are
+// answered from whatever has been indexed so far, including a restored
+// snapshot
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
 // Start the grpc server process
 func (s *Server) Start() error {
-	log.Debugf("grpc ceedeeServer listening on: %d\n", s.port)
-	if err := s.s.Serve(s.l); err != nil {
+	log.Debugf("grpc ceedeeServer listening on: %s\n", s.l.Addr())
+	if err := s.s.Serve(s.l); err != nil && err != grpc.ErrServerStopped {
 		return fmt.Errorf("unable to serve ceedeeServer: %v", err)
 	}
 	return nil
 }
 
-// Stop the grpc server process gracefully
+// Stop the grpc server process immediately, cutting off any in-flight
+// calls, and stop the background walks and history watcher
 func (s *Server) Stop() {
+	for _, p := range s.all() {
+		p.cs.cancel()
+	}
 	s.s.Stop()
+	for _, p := range s.all() {
+		p.cs.wg.Wait()
+	}
+}
+
+// Shutdown stops the server gracefully. It stops accepting connections,
+// cancels any directory walk and the history watcher, waits for in-flight
+// calls to finish and then saves a snapshot of each profile's index and
+// history offset. If ctx is done first the remaining calls are cut off and
+// ctx's error is returned once the snapshots are saved
+func (s *Server) Shutdown(ctx context.Context) error {
+	for _, p := range s.all() {
+		p.cs.cancel()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.s.GracefulStop()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Infoln("Timed out waiting for calls to finish, stopping anyway")
+		s.s.Stop()
+		<-stopped
+		err = ctx.Err()
+	}
+	for _, p := range s.all() {
+		p.cs.wg.Wait()
+		if !p.cs.indexing() {
+			p.cs.readHistory()
+		}
+		p.cs.saveSnapshot()
+	}
+	return err
 }
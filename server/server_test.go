@@ -1,17 +1,28 @@
 package server
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	pb "github.com/walkert/ceedee/ceedeeproto"
 	"github.com/walkert/ceedee/client"
+	"github.com/walkert/ceedee/index"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestHappyPath(t *testing.T) {
 	s, err := New(
 		WithRoot("../testdata"),
-		WithPort(9909),
+		WithPort(0),
 		WithSkipList([]string{"ignore"}),
 		WithHome("/this/home"),
 		WithHistFile("../testdata/histfile"),
@@ -24,9 +35,8 @@ func TestHappyPath(t *testing.T) {
 	go func() {
 		s.Start()
 	}()
-	// Give the hist monitor time to kick in
-	time.Sleep(time.Second * 2)
-	c, err := client.New(9909)
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
 	if err != nil {
 		t.Fatalf("Unexpected error creating client: %v\n", err)
 	}
@@ -79,7 +89,7 @@ func TestHappyPath(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vals, err := c.Get(tt.search)
+			vals, err := c.Get(context.Background(), tt.search)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("Unexpected error getting %s: %v\n", tt.search, err)
@@ -99,3 +109,927 @@ func TestHappyPath(t *testing.T) {
 		})
 	}
 }
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "ceedee.sock")
+	s, err := New(
+		WithRoot("../testdata"),
+		WithSocket(socket),
+		WithHistFile("../testdata/histfile"),
+		WithVersion("test"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Unable to stat socket: %v\n", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket permissions 0600 but got %o", fi.Mode().Perm())
+	}
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the socket to be left in %s but got %v, %v", dir, entries, err)
+	}
+	c, err := client.New(client.WithSocket(socket))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	vals, err := c.Get(context.Background(), "last")
+	if err != nil {
+		t.Fatalf("Unexpected error getting last: %v\n", err)
+	}
+	if len(vals) != 1 || vals[0] != "e;../testdata/top/next/last" {
+		t.Fatalf("Unexpected values: %s", strings.Join(vals, ","))
+	}
+	status, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error getting status: %v\n", err)
+	}
+	if status.Version != "test" || int(status.Pid) != os.Getpid() {
+		t.Fatalf("Unexpected status: %v", status)
+	}
+}
+
+func TestTokenAndTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	token := filepath.Join(dir, "token")
+	cert := filepath.Join(dir, "cert.pem")
+	s, err := New(
+		WithRoot("../testdata"),
+		WithPort(0),
+		WithHistFile("../testdata/histfile"),
+		WithTokenFile(token),
+		WithTLS(cert, filepath.Join(dir, "key.pem")),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	// A certificate which the client should refuse to pin against
+	other := filepath.Join(dir, "other.pem")
+	if err := ensureCert(other, filepath.Join(dir, "other.key")); err != nil {
+		t.Fatalf("Unable to create certificate: %v\n", err)
+	}
+	tests := []struct {
+		name     string
+		opts     []client.Opt
+		errMatch string
+	}{
+		{
+			name: "Authenticated",
+			opts: []client.Opt{client.WithTokenFile(token), client.WithTLS(cert)},
+		},
+		{
+			name:     "NoToken",
+			opts:     []client.Opt{client.WithTLS(cert)},
+			errMatch: "invalid or missing token",
+		},
+		{
+			name:     "WrongCert",
+			opts:     []client.Opt{client.WithTokenFile(token), client.WithTLS(other)},
+			errMatch: "does not match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := client.New(append(tt.opts, client.WithAddress(s.Addr().String()))...)
+			if err != nil {
+				t.Fatalf("Unexpected error creating client: %v\n", err)
+			}
+			vals, err := c.Get(context.Background(), "last")
+			if tt.errMatch == "" {
+				if err != nil || len(vals) != 1 {
+					t.Fatalf("Unexpected result getting last: %v %v", vals, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Fatalf("Expected error to contain '%s' but got: %v\n", tt.errMatch, err)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"one/a/proj/src", "two/proj/src", "two/proj/build"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatalf("Unable to create %s: %v\n", path, err)
+		}
+	}
+	one, two, missing := filepath.Join(dir, "one"), filepath.Join(dir, "two"), filepath.Join(dir, "missing")
+	current := []Opt{WithRoot(one)}
+	s, err := New(
+		WithRoot(one),
+		WithSocket(filepath.Join(dir, "ceedee.sock")),
+		WithHistFile("../testdata/histfile"),
+		WithReloadFunc(func() ([]Opt, error) {
+			return current, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	s.cs.idx.Visit(filepath.Join(one, "a/proj"))
+	tests := []struct {
+		name        string
+		opts        []Opt
+		wantChanges int
+		wantErr     bool
+		search      string
+		want        []string
+	}{
+		{
+			name:   "Unchanged",
+			opts:   []Opt{WithRoot(one)},
+			search: "proj",
+			want:   []string{filepath.Join(one, "a/proj")},
+		},
+		{
+			name:        "AddRoot",
+			opts:        []Opt{WithRoot(one), WithRoots([]string{two})},
+			wantChanges: 1,
+			search:      "src",
+			want:        []string{filepath.Join(two, "proj/src"), filepath.Join(one, "a/proj/src")},
+		},
+		{
+			name:        "ChangeSkipList",
+			opts:        []Opt{WithRoot(one), WithRoots([]string{two}), WithSkipList([]string{"build"})},
+			wantChanges: 2,
+			search:      "build",
+			want:        nil,
+		},
+		{
+			name:        "RemoveRootKeepsVisits",
+			opts:        []Opt{WithRoots([]string{two}), WithSkipList([]string{"build"})},
+			wantChanges: 1,
+			search:      "proj",
+			want:        []string{filepath.Join(one, "a/proj"), filepath.Join(two, "proj")},
+		},
+		{
+			name:        "ChangeWeights",
+			opts:        []Opt{WithRoots([]string{two}), WithSkipList([]string{"build"}), WithWeights(index.Weights{Depth: 1})},
+			wantChanges: 1,
+			search:      "proj",
+			want:        []string{filepath.Join(two, "proj"), filepath.Join(one, "a/proj")},
+		},
+		{
+			name:        "MissingRoot",
+			opts:        []Opt{WithRoots([]string{two, missing}), WithSkipList([]string{"build"}), WithWeights(index.Weights{Depth: 1})},
+			wantChanges: 0,
+			wantErr:     true,
+			search:      "proj",
+			want:        []string{filepath.Join(two, "proj"), filepath.Join(one, "a/proj")},
+		},
+		{
+			name:        "MissingRootRetried",
+			opts:        []Opt{WithRoots([]string{two, missing}), WithSkipList([]string{"build"}), WithWeights(index.Weights{Depth: 1})},
+			wantChanges: 0,
+			wantErr:     true,
+			search:      "proj",
+			want:        []string{filepath.Join(two, "proj"), filepath.Join(one, "a/proj")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current = tt.opts
+			changes, err := s.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected an error %t but got: %v\n", tt.wantErr, err)
+			}
+			if len(changes) != tt.wantChanges {
+				t.Fatalf("Expected %d changes but got: %v", tt.wantChanges, changes)
+			}
+			var got []string
+			for _, r := range s.cs.idx.Query(tt.search) {
+				got = append(got, r.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Wanted %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHistoryTailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(path, []byte("cd /one\n"), 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	tailer, err := newHistoryTailer(path)
+	if err != nil {
+		t.Fatalf("Unexpected error creating tailer: %v\n", err)
+	}
+	tests := []struct {
+		name, write, want string
+		truncate          bool
+	}{
+		{
+			name: "Initial",
+			want: "cd /one\n",
+		},
+		{
+			name: "NothingNew",
+			want: "",
+		},
+		{
+			name:  "PartialLine",
+			write: "cd /tw",
+			want:  "",
+		},
+		{
+			name:  "CompletedLine",
+			write: "o\ncd /three\n",
+			want:  "cd /two\ncd /three\n",
+		},
+		{
+			name:     "Rewritten",
+			write:    "cd /four\n",
+			truncate: true,
+			want:     "cd /four\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := os.O_APPEND | os.O_WRONLY
+			if tt.truncate {
+				flags = os.O_TRUNC | os.O_WRONLY
+			}
+			f, err := os.OpenFile(path, flags, 0600)
+			if err != nil {
+				t.Fatalf("Unable to open history: %v\n", err)
+			}
+			f.WriteString(tt.write)
+			f.Close()
+			got, err := tailer.poll()
+			if err != nil {
+				t.Fatalf("Unexpected error polling: %v\n", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Wanted %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	hist := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(hist, []byte("cd /elsewhere/last\n"), 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	snapshot := filepath.Join(dir, "index.json")
+	s, err := New(
+		WithRoot("../testdata"),
+		WithSocket(filepath.Join(dir, "ceedee.sock")),
+		WithHistFile(hist),
+		WithSnapshot(snapshot),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Start()
+	}()
+	<-s.Ready()
+	s.cs.idx.Visit("/elsewhere/last")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected error shutting down: %v\n", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Unexpected error from Start: %v\n", err)
+	}
+	idx, err := index.RestoreFile(snapshot)
+	if err != nil {
+		t.Fatalf("Unable to restore the snapshot: %v\n", err)
+	}
+	if got := idx.Query("last"); len(got) != 2 || got[0].Path != "/elsewhere/last" {
+		t.Fatalf("Expected the visit to be saved but got: %v", got)
+	}
+	b, err := ioutil.ReadFile(snapshot + ".offsets")
+	if err != nil {
+		t.Fatalf("Unable to read the offsets: %v\n", err)
+	}
+	if want := fmt.Sprintf(`{"%s":19}`, hist); strings.TrimSpace(string(b)) != want {
+		t.Fatalf("Wanted offsets %s, got: %s", want, b)
+	}
+}
+
+func TestWithListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	unix, err := net.Listen("unix", filepath.Join(dir, "inherited.sock"))
+	if err != nil {
+		t.Fatalf("Unable to listen: %v\n", err)
+	}
+	tcp, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v\n", err)
+	}
+	tests := []struct {
+		name string
+		l    net.Listener
+		opt  client.Opt
+	}{
+		{
+			name: "Unix",
+			l:    unix,
+			opt:  client.WithSocket(unix.Addr().String()),
+		},
+		{
+			name: "TCP",
+			l:    tcp,
+			opt:  client.WithAddress(tcp.Addr().String()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(
+				WithRoot("../testdata"),
+				WithListener(tt.l),
+				WithHistFile("../testdata/histfile"),
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error creating server: %v\n", err)
+			}
+			defer s.Stop()
+			go func() {
+				s.Start()
+			}()
+			<-s.Ready()
+			if s.Addr().String() != tt.l.Addr().String() {
+				t.Fatalf("Expected the server to use %s but got %s", tt.l.Addr(), s.Addr())
+			}
+			c, err := client.New(tt.opt)
+			if err != nil {
+				t.Fatalf("Unexpected error creating client: %v\n", err)
+			}
+			defer c.Close()
+			vals, err := c.Get(context.Background(), "last")
+			if err != nil || len(vals) != 1 {
+				t.Fatalf("Unexpected result getting last: %v %v", vals, err)
+			}
+		})
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "ceedee.sock")
+	s, err := New(
+		WithRoot("../testdata"),
+		WithSocket(socket),
+		WithHistFile("../testdata/histfile"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithSocket(socket))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	tests := []struct {
+		name, search string
+		building     bool
+		wantCount    int
+	}{
+		{
+			name:      "Complete",
+			search:    "last",
+			wantCount: 1,
+		},
+		{
+			name:      "BuildingWithMatch",
+			search:    "last",
+			building:  true,
+			wantCount: 1,
+		},
+		{
+			name:     "BuildingWithoutMatch",
+			search:   "badname",
+			building: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			building := int32(0)
+			if tt.building {
+				building = 1
+			}
+			atomic.StoreInt32(&s.cs.building, building)
+			vals, incomplete, err := c.Lookup(context.Background(), tt.search)
+			if err != nil {
+				t.Fatalf("Unexpected error getting %s: %v\n", tt.search, err)
+			}
+			if len(vals) != tt.wantCount || incomplete != tt.building {
+				t.Fatalf("Expected %d values, incomplete %t but got: %v, %t", tt.wantCount, tt.building, vals, incomplete)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	hist := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(hist, []byte("cd /restored/last\n"), 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	snapshot := filepath.Join(dir, "index.json")
+	idx := index.New()
+	idx.AddPath("/restored/last")
+	idx.AddHistory("/restored/last", 1)
+	idx.AddPath("../testdata/top/gone")
+	if err := idx.SnapshotFile(snapshot); err != nil {
+		t.Fatalf("Unable to save a snapshot: %v\n", err)
+	}
+	if err := writeOffsets(snapshot+".offsets", map[string]int64{hist: 18}); err != nil {
+		t.Fatalf("Unable to save the offsets: %v\n", err)
+	}
+	s, err := New(
+		WithRoot("../testdata"),
+		WithSocket(filepath.Join(dir, "ceedee.sock")),
+		WithHistFile(hist),
+		WithSnapshot(snapshot),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	if got := s.cs.idx.Query("last"); len(got) == 0 || got[0].Path != "/restored/last" {
+		t.Fatalf("Expected the snapshot to be restored before the walk but got: %v", got)
+	}
+	<-s.Ready()
+	got := s.cs.idx.Query("last")
+	if len(got) != 2 || got[1].Path != "../testdata/top/next/last" {
+		t.Fatalf("Expected the walk to add to the snapshot but got: %v", got)
+	}
+	if s.cs.idx.Has("gone") {
+		t.Fatalf("Expected the walk to drop a restored directory which no longer exists")
+	}
+	for _, e := range s.cs.idx.Entries() {
+		if e.Path == "/restored/last" && e.Count != 1 {
+			t.Fatalf("Expected history before the saved offset to be skipped but got count %d", e.Count)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s, err := New(
+		WithRoot("../testdata"),
+		WithPort(0),
+		WithSkipList([]string{"ignore"}),
+		WithHistFile("../testdata/histfile"),
+		WithVersion("v1.2.3"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	st, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error getting the status: %v\n", err)
+	}
+	if st.Version != "v1.2.3" || st.Indexing || st.Names == 0 || st.Paths == 0 || st.HeapBytes == 0 {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+	if len(st.LastWalks) != 1 || st.LastWalks[0].Root != "../testdata" {
+		t.Fatalf("Expected the walk of ../testdata but got: %v", st.LastWalks)
+	}
+	hits := st.LastWalks[0].SkipHits
+	if len(hits) != 1 || hits[0].Rule != "ignore" || hits[0].Count != 1 {
+		t.Errorf("Expected one skip by 'ignore' but got: %v", hits)
+	}
+	if len(st.HistoryFiles) != 1 {
+		t.Fatalf("Expected one history file but got: %v", st.HistoryFiles)
+	}
+	if hf := st.HistoryFiles[0]; hf.Offset != hf.Size || hf.Lines == 0 {
+		t.Errorf("Expected the whole history file to be read but got: %+v", hf)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "old"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	hist := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(hist, nil, 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	s, err := New(WithRoot(root), WithPort(0), WithHistFile(hist))
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	if err := os.Rename(filepath.Join(root, "old"), filepath.Join(root, "cloned")); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := c.Reindex(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Unexpected error reindexing: %v\n", err)
+	}
+	if reply.Added != 1 || reply.Removed != 1 {
+		t.Errorf("Expected one directory added and one removed but got: %+v", reply)
+	}
+	if got, err := c.Get(context.Background(), "cloned"); err != nil || len(got) != 1 {
+		t.Errorf("Expected the new directory to be found but got: %v, %v", got, err)
+	}
+	if _, err := c.Reindex(context.Background(), "/elsewhere"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a path outside the roots but got: %v", err)
+	}
+}
+
+func TestMissBudget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	hist := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(hist, nil, 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	root := filepath.Join(dir, "root")
+	for _, p := range []string{"work", "other"} {
+		if err := os.MkdirAll(filepath.Join(root, p), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		budget time.Duration
+		want   []string
+	}{
+		{name: "Disabled", budget: 0, want: []string{}},
+		{name: "Enabled", budget: time.Second, want: []string{"e;" + filepath.Join(root, "work", "fresh")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(WithRoot(root), WithPort(0), WithHistFile(hist), WithMissBudget(tt.budget))
+			if err != nil {
+				t.Fatalf("Unexpected error creating server: %v\n", err)
+			}
+			defer s.Stop()
+			go func() {
+				s.Start()
+			}()
+			<-s.Ready()
+			fresh := filepath.Join(root, "work", "fresh")
+			if err := os.MkdirAll(fresh, 0700); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(fresh)
+			c, err := client.New(client.WithAddress(s.Addr().String()), client.WithCwd(filepath.Join(root, "work")))
+			if err != nil {
+				t.Fatalf("Unexpected error creating client: %v\n", err)
+			}
+			defer c.Close()
+			got, err := c.Get(context.Background(), "fresh")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if strings.Join(got, ":") != strings.Join(tt.want, ":") {
+				t.Errorf("Expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForgetAndBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	blocklist := filepath.Join(dir, "blocklist")
+	if err := ioutil.WriteFile(blocklist, []byte("# build output\n/nowhere/dist"), 0600); err != nil {
+		t.Fatalf("Unable to write the blocklist: %v\n", err)
+	}
+	start := func() (*Server, *client.Client) {
+		s, err := New(
+			WithRoot("../testdata"),
+			WithPort(0),
+			WithHistFile("../testdata/histfile"),
+			WithBlocklist(blocklist),
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error creating server: %v\n", err)
+		}
+		go func() {
+			s.Start()
+		}()
+		<-s.Ready()
+		c, err := client.New(client.WithAddress(s.Addr().String()))
+		if err != nil {
+			t.Fatalf("Unexpected error creating client: %v\n", err)
+		}
+		return s, c
+	}
+	s, c := start()
+	ctx := context.Background()
+	if removed, err := c.Forget(ctx, "../testdata/ign*"); err != nil || removed != 1 {
+		t.Errorf("Expected the pattern to remove one entry but got %d, %v", removed, err)
+	}
+	if removed, err := c.Block(ctx, "../testdata/top/next"); err != nil || removed != 2 {
+		t.Errorf("Expected blocking to remove two entries but got %d, %v", removed, err)
+	}
+	if _, err := c.Block(ctx, "["); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an invalid pattern but got: %v", err)
+	}
+	if _, err := c.Reindex(ctx, ""); err != nil {
+		t.Fatalf("Unexpected error reindexing: %v\n", err)
+	}
+	if !s.cs.idx.Has("ignore") || s.cs.idx.Has("next") {
+		t.Errorf("Expected forgotten entries to come back but blocked ones not to")
+	}
+	c.Close()
+	s.Stop()
+	b, err := ioutil.ReadFile(blocklist)
+	if err != nil || string(b) != "# build output\n/nowhere/dist\n../testdata/top/next\n" {
+		t.Fatalf("Expected the blocklist to be saved but got %q, %v", b, err)
+	}
+	s, c = start()
+	defer s.Stop()
+	defer c.Close()
+	if s.cs.idx.Has("last") {
+		t.Errorf("Expected the saved blocklist to apply after a restart")
+	}
+}
+
+func TestForgetPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ceedee")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	hist := filepath.Join(dir, "histfile")
+	if err := ioutil.WriteFile(hist, []byte("cd /gone/foo\ncd /gone/foo\n"), 0600); err != nil {
+		t.Fatalf("Unable to write history: %v\n", err)
+	}
+	gone := func(s *Server) bool {
+		for _, r := range s.cs.idx.Query("foo") {
+			if r.Path == "/gone/foo" {
+				return false
+			}
+		}
+		return true
+	}
+	start := func() *Server {
+		s, err := New(
+			WithRoot("../testdata"),
+			WithPort(0),
+			WithHistFile(hist),
+			WithSnapshot(filepath.Join(dir, "index.json")),
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error creating server: %v\n", err)
+		}
+		<-s.Ready()
+		return s
+	}
+	s := start()
+	if gone(s) {
+		t.Fatalf("Expected the history to be indexed")
+	}
+	reply, err := s.cs.Forget(context.Background(), &pb.ForgetRequest{Path: "/gone/foo"})
+	if err != nil || reply.Removed != 1 {
+		t.Fatalf("Expected to forget one entry but got %v, %v", reply, err)
+	}
+	s.Stop()
+	s = start()
+	defer s.Stop()
+	if !gone(s) {
+		t.Errorf("Expected the forgotten entry to stay gone after a restart")
+	}
+}
+
+func TestImport(t *testing.T) {
+	s, err := New(WithRoot("../testdata"), WithPort(0), WithHistFile("../testdata/histfile"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	foo, err := filepath.Abs("../testdata/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := c.Import(context.Background(), []*pb.ImportEntry{
+		{Path: foo, Rank: 3},
+		{Path: "/no/longer/here", Rank: 1},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error importing: %v\n", err)
+	}
+	if reply.Imported != 1 || reply.Missing != 1 {
+		t.Errorf("Expected one path imported and one missing but got: %+v", reply)
+	}
+}
+
+func TestMarks(t *testing.T) {
+	s, err := New(WithRoot("../testdata"), WithPort(0), WithHistFile("../testdata/histfile"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.Mark(ctx, "last", "/srv/last"); err != nil {
+		t.Fatalf("Unexpected error marking: %v\n", err)
+	}
+	if err := c.Mark(ctx, "bad/name", "/srv"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad name but got: %v", err)
+	}
+	got, err := c.Get(ctx, "last")
+	want := []string{"b;/srv/last", "e;../testdata/top/next/last"}
+	if err != nil || strings.Join(got, ":") != strings.Join(want, ":") {
+		t.Errorf("Expected %v but got %v, %v", want, got, err)
+	}
+	marks, err := c.Marks(ctx)
+	if err != nil || len(marks) != 1 || marks[0].Name != "last" || marks[0].Path != "/srv/last" {
+		t.Errorf("Unexpected bookmarks: %v, %v", marks, err)
+	}
+	if err := c.Unmark(ctx, "last"); err != nil {
+		t.Errorf("Unexpected error unmarking: %v", err)
+	}
+	if err := c.Unmark(ctx, "last"); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound unmarking twice but got: %v", err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	s, err := New(WithRoot("../testdata"), WithPort(0), WithHistFile("../testdata/histfile"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	c, err := client.New(client.WithAddress(s.Addr().String()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %v\n", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.Tag(ctx, "/srv/api", "backend"); err != nil {
+		t.Fatalf("Unexpected error tagging: %v\n", err)
+	}
+	if err := c.Tag(ctx, "/srv/web", "bad,tag"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad tag but got: %v", err)
+	}
+	got, err := c.Get(ctx, "api @backend")
+	if err != nil || strings.Join(got, ":") != "e;/srv/api" {
+		t.Errorf("Expected e;/srv/api but got %v, %v", got, err)
+	}
+	var listed []string
+	err = c.ListTagged(ctx, "backend", func(e *pb.Entry) error {
+		listed = append(listed, e.Path+" "+e.Source+" "+strings.Join(e.Tags, ","))
+		return nil
+	})
+	if err != nil || len(listed) != 1 || listed[0] != "/srv/api tag backend" {
+		t.Errorf("Expected only /srv/api to be listed but got %v, %v", listed, err)
+	}
+	if n, err := c.Untag(ctx, "/srv/api"); err != nil || n != 1 {
+		t.Errorf("Expected to remove 1 tag but got %d, %v", n, err)
+	}
+	if _, err := c.Untag(ctx, "/srv/api"); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound untagging twice but got: %v", err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	s, err := New(WithRoot("../testdata"), WithPort(0), WithHistFile("../testdata/histfile"),
+		WithProfile("work", WithRoot("../testdata/top"), WithHistFile("../testdata/histfile")),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v\n", err)
+	}
+	defer s.Stop()
+	go func() {
+		s.Start()
+	}()
+	<-s.Ready()
+	ctx := context.Background()
+	clients := make(map[string]*client.Client)
+	for _, name := range []string{"", "work", "missing"} {
+		c, err := client.New(client.WithAddress(s.Addr().String()), client.WithProfile(name))
+		if err != nil {
+			t.Fatalf("Unexpected error creating client: %v\n", err)
+		}
+		defer c.Close()
+		clients[name] = c
+	}
+	if got, err := clients[""].Get(ctx, "foo"); err != nil || len(got) == 0 {
+		t.Errorf("Expected foo in the default profile but got %v, %v", got, err)
+	}
+	if got, err := clients["work"].Get(ctx, "foo"); err != nil || len(got) != 0 {
+		t.Errorf("Expected no foo in the work profile but got %v, %v", got, err)
+	}
+	if err := clients["work"].Tag(ctx, "/srv/api", "backend"); err != nil {
+		t.Fatalf("Unexpected error tagging: %v", err)
+	}
+	if got, _ := clients[""].Get(ctx, "@backend"); len(got) != 0 {
+		t.Errorf("Expected the work profile's tags to stay out of the default profile but got %v", got)
+	}
+	st, err := clients["work"].Status(ctx)
+	if err != nil || st.Profile != "work" || strings.Join(st.Profiles, ",") != "default,work" {
+		t.Errorf("Unexpected profiles in status: %v, %v", st, err)
+	}
+	if _, err := clients["missing"].Get(ctx, "foo"); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown profile but got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listenUnix creates a unix domain socket at path which is only accessible
+// by the current user. A stale socket left behind by a previous server is
+// removed, but one which still has a server listening on it is not.
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a server is already listening on %s", path)
+		}
+		log.Debugln("Removing stale socket", path)
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	// The socket is created with the umask's permissions, so it is bound
+	// in a directory which only the current user can enter and only moved
+	// into place once its permissions have been narrowed
+	private, err := ioutil.TempDir(filepath.Dir(path), ".ceedee")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(private)
+	bound := filepath.Join(private, "s")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: bound, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	lis.SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	if err := os.Rename(bound, path); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return &peerCredListener{Listener: &movedListener{UnixListener: lis, path: path}, uid: os.Getuid()}, nil
+}
+
+// movedListener is a unix domain socket listener whose socket has been
+// moved to path after it was bound
+type movedListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Addr returns the address of the socket where it now is
+func (l *movedListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Close stops listening and removes the socket
+func (l *movedListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// peerCredListener is a net.Listener which drops any connection that was
+// not made by the user with the given uid
+type peerCredListener struct {
+	net.Listener
+	uid int
+}
+
+// Accept waits for and returns the next connection from the owning user
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		uid, err := peerUID(conn)
+		if err != nil {
+			log.Debugf("Unable to verify the owner of a connection: %v\n", err)
+			conn.Close()
+			continue
+		}
+		if uid != l.uid {
+			log.Infof("Rejected a connection from uid %d\n", uid)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
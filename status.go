@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	pb "github.com/walkert/ceedee/ceedeeproto"
+)
+
+// showStatus asks the server for its status and writes a report of it to w
+func showStatus(o *options, w io.Writer) error {
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	status, err := c.Status(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to get the server status: %v", err)
+	}
+	printStatus(w, status, time.Now())
+	return nil
+}
+
+// printStatus writes a human readable report of status as it was at now
+func printStatus(w io.Writer, status *pb.ServerStatus, now time.Time) {
+	uptime := now.Sub(time.Unix(status.StartTime, 0)).Round(time.Second)
+	fmt.Fprintf(w, "Version:  %s\n", status.Version)
+	fmt.Fprintf(w, "Pid:      %d\n", status.Pid)
+	fmt.Fprintf(w, "Uptime:   %s\n", uptime)
+	if len(status.Profiles) > 1 {
+		fmt.Fprintf(w, "Profile:  %s (of %s)\n", status.Profile, strings.Join(status.Profiles, ", "))
+	}
+	fmt.Fprintf(w, "Memory:   %s heap, %s from the OS\n", formatBytes(status.HeapBytes), formatBytes(status.SysBytes))
+	fmt.Fprintf(w, "Index:    %d names, %d paths, %d with history\n", status.Names, status.Paths, status.HistoryEntries)
+	if status.Indexing {
+		fmt.Fprintln(w, "The index is still being built")
+	}
+	for _, walk := range status.Walks {
+		elapsed := now.Sub(time.Unix(walk.StartTime, 0)).Round(time.Second)
+		fmt.Fprintf(w, "Walking %s: %d directories in %s\n", walk.Root, walk.Dirs, elapsed)
+	}
+	for _, walk := range status.LastWalks {
+		duration := time.Duration(walk.DurationMs) * time.Millisecond
+		fmt.Fprintf(w, "Last walk of %s:\n", walk.Root)
+		fmt.Fprintf(w, "  started %s, took %s\n", time.Unix(walk.StartTime, 0).Format(time.RFC3339), duration)
+		fmt.Fprintf(w, "  %d directories, %d unreadable\n", walk.Dirs, walk.ErrorSkips)
+		if walk.Truncated != "" {
+			fmt.Fprintf(w, "  truncated: %s\n", walk.Truncated)
+		}
+		for _, hit := range walk.SkipHits {
+			fmt.Fprintf(w, "  skipped %d by %s\n", hit.Count, hit.Rule)
+		}
+	}
+	for _, hf := range status.HistoryFiles {
+		fmt.Fprintf(w, "History %s:\n", hf.Path)
+		fmt.Fprintf(w, "  read %d of %d bytes, %d lines with %d parse failures since startup\n", hf.Offset, hf.Size, hf.Lines, hf.ParseFailures)
+	}
+}
+
+// formatBytes returns n in the largest binary unit that keeps it above one
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/walkert/ceedee/ceedeeproto"
+)
+
+// tagCommand runs 'tag add' and 'tag rm', which label a directory so that
+// it can be found with an '@tag' query
+func tagCommand(o *options, args []string) error {
+	if len(args) < 3 || (args[1] != "add" && args[1] != "rm") || (args[1] == "add" && len(args) < 4) {
+		return fmt.Errorf("Usage: ceedee tag add <path> <tag>... | tag rm <path> [tag]...")
+	}
+	abs, err := absPath(o, args[2])
+	if err != nil {
+		return err
+	}
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	tags := args[3:]
+	if args[1] == "add" {
+		if err := c.Tag(context.Background(), abs, tags...); err != nil {
+			return fmt.Errorf("unable to tag %s: %v", abs, err)
+		}
+		return nil
+	}
+	removed, err := c.Untag(context.Background(), abs, tags...)
+	if err != nil {
+		return fmt.Errorf("unable to untag %s: %v", abs, err)
+	}
+	fmt.Printf("Removed %d tags from %s\n", removed, abs)
+	return nil
+}
+
+// listPaths prints every indexed path, or only those carrying tag if it
+// isn't empty
+func listPaths(o *options, tag string) error {
+	c, _, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	show := func(e *pb.Entry) error {
+		_, err := fmt.Println(e.Path)
+		return err
+	}
+	if tag != "" {
+		err = c.ListTagged(context.Background(), tag, show)
+	} else {
+		err = c.ListFunc(context.Background(), show)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to list the index: %v", err)
+	}
+	return nil
+}
@@ -1,3 +1,3 @@
 c () {
-	cd $(ceedee $1)
+	cd $(ceedee "$@")
 }